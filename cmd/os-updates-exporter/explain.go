@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/R4VXN/os-updates-exporter/internal/collector"
+	"github.com/R4VXN/os-updates-exporter/internal/config"
+	"github.com/R4VXN/os-updates-exporter/internal/lock"
+	"github.com/R4VXN/os-updates-exporter/internal/risk"
+	"github.com/R4VXN/os-updates-exporter/internal/runner"
+	"github.com/R4VXN/os-updates-exporter/internal/state"
+)
+
+// riskBreakdown is the risk score of a host: per manager and for the factors
+// that concern the whole host.
+type riskBreakdown struct {
+	Managers []managerRisk
+	Host     []risk.Component
+}
+
+type managerRisk struct {
+	Manager    string
+	Score      float64
+	Components []risk.Component
+}
+
+// scoreRisk evaluates the model for every manager of res. secAge holds the age
+// of the oldest pending security update per manager (seconds); kev is the
+// known exploited vulnerabilities catalog (nil = not configured).
+func scoreRisk(m risk.Model, res collector.Result, secAge map[string]float64, kev map[string]bool, now time.Time) riskBreakdown {
+	rb := riskBreakdown{}
+	for _, mr := range res.Managers {
+		v := map[string]float64{
+			risk.Bugfix:          float64(mr.PendingBugfix),
+			risk.Kernel:          0,
+			risk.RepoUnreachable: float64(mr.Repo.Unreachable),
+			risk.SecurityAgeDays: secAge[mr.Manager] / 86400,
+		}
+		classified := 0
+		for _, sev := range collector.Severities {
+			v["security_"+sev] = float64(mr.SecurityBySeverity[sev])
+			classified += mr.SecurityBySeverity[sev]
+		}
+		if rest := mr.PendingSecurity - classified; rest > 0 {
+			v[risk.SecurityUnknown] += float64(rest)
+		}
+		if strings.ToLower(res.RebootReason) == "kernel" {
+			v[risk.Kernel] = float64(mr.PendingSecurity)
+		}
+		if kev != nil {
+			v[risk.KEV] = float64(len(collector.KEVCVEs(mr.CVEs, kev)))
+		}
+		score, comps := m.Score(v)
+		rb.Managers = append(rb.Managers, managerRisk{Manager: mr.Manager, Score: score, Components: comps})
+	}
+	_, rb.Host = m.Score(map[string]float64{
+		risk.Reboot: boolValue(res.RebootRequired),
+		risk.EOL:    boolValue(!res.Supported(now)),
+	})
+	return rb
+}
+
+// Total is the host score: the manager scores and the host factors.
+func (rb riskBreakdown) Total() float64 {
+	total := 0.0
+	for _, c := range rb.Components(nil) {
+		total += c.Points
+	}
+	return total
+}
+
+// Components sums the points per factor over the managers and the host, in
+// the order of m (in order of appearance if m is nil).
+func (rb riskBreakdown) Components(m risk.Model) []risk.Component {
+	sum := map[string]risk.Component{}
+	order := []string{}
+	add := func(c risk.Component) {
+		s, ok := sum[c.Name]
+		if !ok {
+			order = append(order, c.Name)
+			s = risk.Component{Factor: c.Factor}
+		}
+		s.Value += c.Value
+		s.Points += c.Points
+		sum[c.Name] = s
+	}
+	for _, mr := range rb.Managers {
+		for _, c := range mr.Components {
+			add(c)
+		}
+	}
+	for _, c := range rb.Host {
+		add(c)
+	}
+	if m != nil {
+		order = order[:0]
+		for _, f := range m {
+			if _, ok := sum[f.Name]; ok {
+				order = append(order, f.Name)
+			}
+		}
+	}
+	out := make([]risk.Component, 0, len(order))
+	for _, name := range order {
+		out = append(out, sum[name])
+	}
+	return out
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// explain collects like a run, without writing the textfile or the state, and
+// prints how the risk score was computed.
+func explain() int {
+	cfg, err := config.LoadFromEnv()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "config:", err)
+		return 1
+	}
+	cmds := runner.New()
+	cmds.Root = cfg.Sysroot
+	return runExplain(cfg, cmds, cfg.Sysroot, os.Stdout)
+}
+
+func runExplain(cfg config.Config, cmds runner.Runner, root string, w io.Writer) int {
+	model, err := risk.Load(cfg.RiskModelFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "risk model:", err)
+		return 1
+	}
+	l, err := lock.Acquire(cfg.LockFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "lock:", err)
+		return 75
+	}
+	defer l.Release()
+
+	// ages are taken from a copy of the state that is not saved
+	st, err := state.Load(cfg.StateFile)
+	if err != nil {
+		st = state.New()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.PkgmgrTimeout)
+	defer cancel()
+	env := &collector.Env{Cfg: cfg, Run: cmds, Root: root}
+	res, cerr := collector.Collect(ctx, env)
+	if cerr != nil {
+		fmt.Fprintln(os.Stderr, "collect:", cerr)
+	}
+	if !cfg.OfflineMode {
+		rctx, rcancel := context.WithTimeout(context.Background(), cfg.RepoHeadTimeout)
+		defer rcancel()
+		for i := range res.Managers {
+			res.Managers[i].Repo, _ = collector.CheckRepos(rctx, env, res.Managers[i].Manager)
+		}
+	}
+	now := time.Now()
+	secAge := map[string]float64{}
+	for _, mr := range res.Managers {
+		if ages, ok := trackAges(st, mr, now.Unix()); ok {
+			secAge[mr.Manager] = ages["security"]
+		}
+	}
+	var kev map[string]bool
+	if cfg.KEVFile != "" {
+		if kev, err = collector.LoadKEV(cfg.KEVFile); err != nil {
+			fmt.Fprintln(os.Stderr, "kev:", err)
+		}
+	}
+	rb := scoreRisk(model, res, secAge, kev, now)
+
+	source := "default"
+	if cfg.RiskModelFile != "" {
+		source = cfg.RiskModelFile
+	}
+	fmt.Fprintf(w, "risk model: %s\n\n", source)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SCOPE\tFACTOR\tVALUE\tWEIGHT\tCAP\tPOINTS")
+	row := func(scope string, c risk.Component) {
+		capText := "-"
+		if c.Cap > 0 {
+			capText = formatFloat(c.Cap)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", scope, c.Name, formatFloat(c.Value), formatFloat(c.Weight), capText, formatFloat(c.Points))
+	}
+	for _, mr := range rb.Managers {
+		for _, c := range mr.Components {
+			row(mr.Manager, c)
+		}
+		fmt.Fprintf(tw, "%s\tscore\t\t\t\t%s\n", mr.Manager, formatFloat(mr.Score))
+	}
+	for _, c := range rb.Host {
+		row("host", c)
+	}
+	fmt.Fprintf(tw, "total\t\t\t\t\t%s\n", formatFloat(rb.Total()))
+	_ = tw.Flush()
+	return 0
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
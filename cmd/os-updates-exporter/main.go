@@ -3,16 +3,21 @@ package main
 import (
 	"context"
 	"fmt"
+	"math"
 	"os"
+	"sort"
 	"time"
 
 	"github.com/R4VXN/os-updates-exporter/internal/collector"
 	"github.com/R4VXN/os-updates-exporter/internal/config"
 	"github.com/R4VXN/os-updates-exporter/internal/lock"
 	"github.com/R4VXN/os-updates-exporter/internal/metrics"
+	"github.com/R4VXN/os-updates-exporter/internal/risk"
+	"github.com/R4VXN/os-updates-exporter/internal/runner"
 	"github.com/R4VXN/os-updates-exporter/internal/state"
 	"github.com/R4VXN/os-updates-exporter/internal/systemd"
 	"github.com/R4VXN/os-updates-exporter/internal/updater"
+	"github.com/R4VXN/os-updates-exporter/internal/version"
 )
 
 var (
@@ -32,6 +37,8 @@ func main() {
 		os.Exit(run())
 	case "updater":
 		os.Exit(runUpdater(args[1:]))
+	case "explain":
+		os.Exit(explain())
 	case "install":
 		os.Exit(systemd.InstallCollectorUnits())
 	case "uninstall":
@@ -41,7 +48,7 @@ func main() {
 		os.Exit(0)
 	default:
 		fmt.Fprintf(os.Stderr, "unknown command: %s\n", args[0])
-		fmt.Fprintf(os.Stderr, "usage: %s [run|updater|install|uninstall|version]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "usage: %s [run|explain|updater|install|uninstall|version]\n", os.Args[0])
 		os.Exit(2)
 	}
 }
@@ -52,7 +59,15 @@ func run() int {
 		fmt.Fprintln(os.Stderr, "config:", err)
 		return 1
 	}
+	cmds := runner.New()
+	cmds.Root = cfg.Sysroot
+	return runOnce(cfg, cmds, cfg.Sysroot)
+}
 
+// runOnce performs one collection run with the given command runner, reading
+// host files below root (cfg.Sysroot, or a fixture in tests), and writes the
+// textfile and the state.
+func runOnce(cfg config.Config, cmds runner.Recorder, root string) int {
 	start := time.Now()
 	reg := metrics.NewRegistry()
 	reg.SetBuildInfo(Version, Commit, GoVersion)
@@ -84,10 +99,14 @@ func run() int {
 	pkgStart := time.Now()
 	ctx, cancel := context.WithTimeout(context.Background(), cfg.PkgmgrTimeout)
 	defer cancel()
-	res, perr := collector.Collect(ctx, cfg)
+	env := &collector.Env{Cfg: cfg, Run: cmds, Root: root}
+	res, perr := collector.Collect(ctx, env)
 	if perr != nil {
 		reg.SetStageError("pkgmgr", true)
 	}
+	for _, mr := range res.Managers {
+		reg.SetManagerError(mr.Manager, mr.Err != nil)
+	}
 	reg.SetStageDuration("pkgmgr", time.Since(pkgStart))
 
 	// repo checks
@@ -95,52 +114,242 @@ func run() int {
 	if !cfg.OfflineMode {
 		rctx, rcancel := context.WithTimeout(context.Background(), cfg.RepoHeadTimeout)
 		defer rcancel()
-		rres, rerr := collector.CheckRepos(rctx, cfg, res.Manager)
-		if rerr != nil {
+		repoErr := false
+		for i := range res.Managers {
+			rres, rerr := collector.CheckRepos(rctx, env, res.Managers[i].Manager)
+			if rerr != nil {
+				repoErr = true
+			}
+			res.Managers[i].Repo = rres
+		}
+		if repoErr {
 			reg.SetStageError("repo", true)
 			if !cfg.FailOpen {
 				reg.SetScrapeSuccess(false)
 			}
 		}
-		res.Repo = rres
 	}
 	reg.SetStageDuration("repo", time.Since(repoStart))
 
-	// populate metrics
-	reg.SetInfo(res.Manager, cfg.TextfileDir, res.OSName, res.OSVersion, cfg.PatchThreshold)
-	reg.SetPending(res.Manager, "security", res.PendingSecurity)
-	reg.SetPending(res.Manager, "bugfix", res.PendingBugfix)
-	reg.SetPending(res.Manager, "all", res.PendingAll)
+	// release upgrade, checked at most once a day per installed release
+	releaseStart := time.Now()
+	release := res.OSID + " " + res.OSVersion
+	target, checked := st.CachedReleaseUpgrade(release, releaseStart.Unix(), releaseCheckInterval)
+	if !checked && cfg.OfflineMode {
+		target, checked = st.CachedReleaseUpgrade(release, releaseStart.Unix(), math.MaxInt64)
+	} else if !checked {
+		uctx, ucancel := context.WithTimeout(context.Background(), cfg.PkgmgrTimeout)
+		defer ucancel()
+		t, ok, uerr := collector.CheckReleaseUpgrade(uctx, env, res)
+		switch {
+		case uerr != nil:
+			reg.SetStageError("release", true)
+		case ok:
+			target, checked = t, true
+			st.SetReleaseUpgrade(release, target, releaseStart.Unix())
+		}
+	}
+	if checked {
+		reg.SetReleaseUpgrade(target)
+	}
+	reg.SetStageDuration("release", time.Since(releaseStart))
+
+	// known vulnerabilities of the installed packages (offline OSV feed)
+	var vulns collector.VulnResult
+	vulnsOK := false
+	if cfg.OSVDir != "" {
+		vulnStart := time.Now()
+		vctx, vcancel := context.WithTimeout(context.Background(), cfg.PkgmgrTimeout)
+		defer vcancel()
+		vr, ok, verr := collector.MatchOSV(vctx, env, res)
+		if verr != nil {
+			reg.SetStageError("vuln", true)
+		} else {
+			vulns, vulnsOK = vr, ok
+		}
+		reg.SetStageDuration("vuln", time.Since(vulnStart))
+	}
+
+	// vendor OVAL definitions evaluated against the rpm database
+	var oval map[string]int
+	if cfg.OVALDir != "" {
+		ovalStart := time.Now()
+		octx, ocancel := context.WithTimeout(context.Background(), cfg.PkgmgrTimeout)
+		defer ocancel()
+		affected, ok, oerr := collector.EvaluateOVAL(octx, env, res)
+		switch {
+		case oerr != nil:
+			reg.SetStageError("oval", true)
+		case ok:
+			oval = affected
+		}
+		reg.SetStageDuration("oval", time.Since(ovalStart))
+	}
 
-	// deltas + aging via state
-	prev := st.GetManager(res.Manager)
-	reg.SetNewPending(res.Manager, "security", max0(res.PendingSecurity-prev.PendingSecurity))
-	reg.SetNewPending(res.Manager, "bugfix", max0(res.PendingBugfix-prev.PendingBugfix))
-	reg.SetNewPending(res.Manager, "all", max0(res.PendingAll-prev.PendingAll))
+	// known exploited vulnerabilities catalog
+	var kev map[string]bool
+	if cfg.KEVFile != "" {
+		kevStart := time.Now()
+		k, kerr := collector.LoadKEV(cfg.KEVFile)
+		if kerr != nil {
+			reg.SetStageError("kev", true)
+		} else {
+			kev = k
+		}
+		reg.SetStageDuration("kev", time.Since(kevStart))
+	}
+
+	for _, c := range runner.Summarize(cmds.Runs()) {
+		reg.SetCommandDuration(c.Command, c.Duration)
+		reg.SetCommandExitCode(c.Command, c.ExitCode)
+		codes := make([]int, 0, len(c.ExitCodes))
+		for code := range c.ExitCodes {
+			codes = append(codes, code)
+		}
+		sort.Ints(codes)
+		for _, code := range codes {
+			reg.SetCommandRuns(c.Command, code, c.ExitCodes[code])
+		}
+	}
 
+	// populate metrics
+	for _, name := range res.ManagerNames() {
+		reg.SetInfo(name, cfg.TextfileDir, res.OSName, res.OSVersion, cfg.PatchThreshold)
+	}
 	now := time.Now().Unix()
-	ageAll := st.UpdateOldestSeen(res.Manager, "all", res.PendingAll, now)
-	ageSec := st.UpdateOldestSeen(res.Manager, "security", res.PendingSecurity, now)
-	ageBug := st.UpdateOldestSeen(res.Manager, "bugfix", res.PendingBugfix, now)
-	reg.SetOldestAge(res.Manager, "all", ageAll)
-	reg.SetOldestAge(res.Manager, "security", ageSec)
-	reg.SetOldestAge(res.Manager, "bugfix", ageBug)
-
-	// repo metrics
-	if res.Repo.Valid {
-		reg.SetRepoTotals(res.Manager, res.Repo.Total, res.Repo.Unreachable)
-		reg.SetRepoNewlyUnreachable(res.Manager, max0(res.Repo.Unreachable-prev.RepoUnreachable))
-		reg.SetRepoMetadataAge(res.Manager, res.Repo.MetadataAgeSeconds)
-		reg.SetRepoHeadLatency(res.Manager, res.Repo.HeadLatencySeconds)
-	}
-
-	// reboot + maintenance + compliance + risk
+	secAge := map[string]float64{}
+	for _, mr := range res.Managers {
+		for _, pkgState := range collector.States {
+			reg.SetPending(mr.Manager, "bugfix", pkgState, mr.ByState[pkgState]-mr.SecurityByState[pkgState])
+			reg.SetPending(mr.Manager, "all", pkgState, mr.ByState[pkgState])
+			for _, sev := range collector.Severities {
+				reg.SetPendingSeverity(mr.Manager, pkgState, sev, mr.SecurityByStateSeverity[pkgState][sev])
+			}
+		}
+		for _, b := range version.Bumps {
+			reg.SetPendingByBump(mr.Manager, b, mr.ByBump[b])
+		}
+		reg.SetPendingHeld(mr.Manager, mr.PendingHeld)
+		reg.SetHeldPackages(mr.Manager, mr.Held)
+		if mr.HoldUntil != 0 {
+			reg.SetHoldExpiry(mr.Manager, mr.HoldUntil)
+		}
+		for _, p := range mr.TopPackages(cfg.TopNPackages) {
+			reg.SetPackageInfo(mr.Manager, p.Name, p.Arch, p.InstalledVersion, p.CandidateVersion, p.Repo, p.Class)
+		}
+
+		// deltas + aging via state
+		prev := st.GetManager(mr.Manager)
+		reg.SetNewPending(mr.Manager, "security", max0(mr.PendingSecurity-prev.PendingSecurity))
+		reg.SetNewPending(mr.Manager, "bugfix", max0(mr.PendingBugfix-prev.PendingBugfix))
+		reg.SetNewPending(mr.Manager, "all", max0(mr.PendingAll-prev.PendingAll))
+
+		if ages, ok := trackAges(st, mr, now); ok {
+			for _, class := range []string{"all", "security", "bugfix"} {
+				reg.SetOldestAge(mr.Manager, class, ages[class])
+			}
+			secAge[mr.Manager] = ages["security"]
+		}
+		for _, class := range []string{"security", "bugfix"} {
+			if h, ok := st.TimeToPatch[mr.Manager+"/"+class]; ok {
+				reg.SetTimeToPatch(mr.Manager, class, state.TimeToPatchBuckets, h.Counts, h.Sum, h.Count)
+			}
+		}
+
+		// upgrade history
+		if h := mr.History; h.Valid {
+			if !h.LastUpgrade.IsZero() {
+				reg.SetLastUpgrade(mr.Manager, h.LastUpgrade.Unix())
+			}
+			if !h.LastSecurityUpgrade.IsZero() {
+				reg.SetLastSecurityUpgrade(mr.Manager, h.LastSecurityUpgrade.Unix())
+			}
+			reg.SetUpgradedPackages(mr.Manager, "24h", h.Upgraded24h)
+			reg.SetUpgradedPackages(mr.Manager, "7d", h.Upgraded7d)
+		}
+
+		// repo metrics
+		if mr.Repo.Valid {
+			reg.SetRepoTotals(mr.Manager, mr.Repo.Total, mr.Repo.Unreachable)
+			reg.SetRepoNewlyUnreachable(mr.Manager, max0(mr.Repo.Unreachable-prev.RepoUnreachable))
+			reg.SetRepoMetadataAge(mr.Manager, mr.Repo.MetadataAgeSeconds)
+			reg.SetRepoHeadLatency(mr.Manager, mr.Repo.HeadLatencySeconds)
+		}
+	}
+
+	// CVEs referenced by pending advisories
+	cves := res.CVEs()
+	cveIDs := make([]string, 0, len(cves))
+	cveBySev := map[string]int{}
+	for id, sev := range cves {
+		cveIDs = append(cveIDs, id)
+		cveBySev[sev]++
+	}
+	for _, sev := range collector.Severities {
+		reg.SetPendingCVEs(sev, cveBySev[sev])
+	}
+	// a failed manager may not have reported all its CVEs: keep the (KEV)
+	// first-seen times of those until it succeeds again
+	partial := false
+	for _, mr := range res.Managers {
+		partial = partial || mr.Err != nil
+	}
+	cveSeen := st.TrackCVEs(cveIDs, partial, now)
+	if cfg.CVEDetails {
+		for _, id := range collector.TopCVEs(cves, cfg.TopNCVEs) {
+			reg.SetCVEFirstSeen(id, cves[id], cveSeen[id])
+		}
+	}
+	if kev != nil {
+		kevIDs := collector.KEVCVEs(cves, kev)
+		oldest := 0.0
+		for _, ts := range st.TrackKEV(kevIDs, partial, now) {
+			oldest = math.Max(oldest, float64(now-ts))
+		}
+		reg.SetPendingKEV(len(kevIDs), oldest)
+	}
+	if vulnsOK {
+		for _, sev := range collector.Severities {
+			reg.SetVulnerablePackages(sev, vulns.Packages[sev])
+			reg.SetOpenVulnerabilities(sev, vulns.Vulns[sev])
+		}
+	}
+	if oval != nil {
+		for _, sev := range collector.Severities {
+			reg.SetOVALAffected(sev, oval[sev])
+		}
+	}
+
+	// reboot + maintenance + eol + compliance + risk
 	reg.SetReboot(res.RebootRequired)
 	reg.SetRebootReason(res.RebootReason)
 	reg.SetMaintenanceWindow(cfg.InMaintenanceWindow())
-	reg.SetCompliant(res.PendingAll <= cfg.PatchThreshold)
-	reg.SetCompliantEffective(res.EffectiveCompliant(cfg))
-	reg.SetRiskScore(res.Manager, res.RiskScore)
+	if !res.EOL.IsZero() {
+		reg.SetReleaseEOL(res.EOL.Unix())
+	}
+	reg.SetReleaseSupported(res.Supported(time.Now()))
+	// snap and flatpak application updates don't count against compliance
+	osRes := res.OSPackages()
+	reg.SetCompliant(osRes.PendingAll <= cfg.PatchThreshold)
+	reg.SetCompliantEffective(osRes.EffectiveCompliant(cfg))
+	sla := evaluateSLA(st, osRes, cfg, now)
+	for _, class := range collector.SLAClasses {
+		if s, ok := sla[class]; ok {
+			reg.SetSLA(class, s.Breached, s.Remaining)
+		}
+	}
+	model, err := risk.Load(cfg.RiskModelFile)
+	if err != nil {
+		reg.SetStageError("risk", true)
+		model = risk.Default()
+	}
+	rb := scoreRisk(model, res, secAge, kev, time.Now())
+	for _, m := range rb.Managers {
+		reg.SetRiskScore(m.Manager, m.Score)
+	}
+	for _, c := range rb.Components(model) {
+		reg.SetRiskComponent(c.Name, c.Points)
+	}
 
 	// run durations
 	reg.SetLastRun(time.Now())
@@ -164,17 +373,16 @@ func run() int {
 
 	// persist state (non-fatal if fails)
 	st.LastRunTS = now
-	st.SetManager(res.Manager, state.ManagerState{
-		PendingAll:         res.PendingAll,
-		PendingSecurity:    res.PendingSecurity,
-		PendingBugfix:      res.PendingBugfix,
-		RepoUnreachable:    res.Repo.Unreachable,
-		RepoTotal:          res.Repo.Total,
-		RebootRequired:     res.RebootRequired,
-		OldestAllSeen:      st.Oldest(res.Manager, "all"),
-		OldestSecuritySeen: st.Oldest(res.Manager, "security"),
-		OldestBugfixSeen:   st.Oldest(res.Manager, "bugfix"),
-	})
+	for _, mr := range res.Managers {
+		st.SetManager(mr.Manager, state.ManagerState{
+			PendingAll:      mr.PendingAll,
+			PendingSecurity: mr.PendingSecurity,
+			PendingBugfix:   mr.PendingBugfix,
+			RepoUnreachable: mr.Repo.Unreachable,
+			RepoTotal:       mr.Repo.Total,
+			RebootRequired:  res.RebootRequired,
+		})
+	}
 	if err := state.SaveAtomic(cfg.StateFile, st); err != nil {
 		// keep metrics; expose via stage error
 		// (prom already written)
@@ -238,6 +446,40 @@ func runUpdater(args []string) int {
 	}
 }
 
+// trackAges updates the per-package first-seen times of mr and returns the age
+// of the oldest pending update per class (all, security, bugfix). A failed
+// manager may have reported only part of its updates: its tracked packages are
+// kept for the next run and ok is false.
+func trackAges(st *state.State, mr collector.ManagerResult, now int64) (ages map[string]float64, ok bool) {
+	if mr.Err != nil {
+		return nil, false
+	}
+	pending := map[string]string{}
+	for _, p := range mr.Packages {
+		pending[packageKey(p)] = p.Class
+	}
+	seen := st.TrackPackages(mr.Manager, pending, now)
+	ages = map[string]float64{"all": 0, "security": 0, "bugfix": 0}
+	for _, p := range mr.Packages {
+		age := float64(now - seen[packageKey(p)])
+		for _, class := range []string{"all", p.Class} {
+			if age > ages[class] {
+				ages[class] = age
+			}
+		}
+	}
+	return ages, true
+}
+
+// packageKey identifies a pending update across runs: a new installed version
+// means the update was applied.
+func packageKey(p collector.Package) string {
+	return p.Name + "." + p.Arch + " " + p.InstalledVersion
+}
+
+// releaseCheckInterval is how long a release upgrade check is cached (seconds).
+const releaseCheckInterval = 24 * 60 * 60
+
 func max0(v int) int {
 	if v < 0 {
 		return 0
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/R4VXN/os-updates-exporter/internal/collector"
+	"github.com/R4VXN/os-updates-exporter/internal/config"
+	"github.com/R4VXN/os-updates-exporter/internal/runner/runnertest"
+	"github.com/R4VXN/os-updates-exporter/internal/state"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata/golden")
+
+const fixtures = "../../internal/collector/testdata"
+
+// fixtureConfig adjusts the configuration for fixtures that need it.
+var fixtureConfig = map[string]func(*config.Config){
+	"ubuntu-24.04": func(c *config.Config) {
+		c.AptNative = true
+		c.OSVDir = filepath.Join(fixtures, "ubuntu-24.04", "osv")
+	},
+	"sles-15": func(c *config.Config) {
+		c.OSVDir = filepath.Join(fixtures, "sles-15", "osv")
+		c.OVALDir = filepath.Join(fixtures, "sles-15", "oval")
+	},
+	"rhel-9": func(c *config.Config) { c.OVALDir = filepath.Join(fixtures, "rhel-9", "oval") },
+}
+
+// TestRunGolden runs the exporter against every collector fixture and compares
+// the textfile with testdata/golden/<fixture>.prom. Run with -update to accept
+// changes.
+func TestRunGolden(t *testing.T) {
+	// history logs are in local time
+	defer func(loc *time.Location) { time.Local = loc }(time.Local)
+	time.Local = time.UTC
+
+	dirs, err := os.ReadDir(fixtures)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range dirs {
+		if !d.IsDir() {
+			continue
+		}
+		name := d.Name()
+		t.Run(name, func(t *testing.T) {
+			run, err := runnertest.Load(filepath.Join(fixtures, name, "commands.txt"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			out := t.TempDir()
+			cfg := config.Config{
+				TextfileDir:     out,
+				StateFile:       filepath.Join(out, "state.json"),
+				LockFile:        filepath.Join(out, "os-updates-exporter.lock"),
+				FileMode:        0600,
+				PatchThreshold:  3,
+				TopNPackages:    5,
+				CVEDetails:      true,
+				TopNCVEs:        100,
+				RepoHeadTimeout: 5 * time.Second,
+				PkgmgrTimeout:   10 * time.Second,
+				OfflineMode:     true,
+				FailOpen:        true,
+				EOLFile:         filepath.Join("testdata", "eol.txt"),
+				KEVFile:         filepath.Join("testdata", "kev.json"),
+				SLA: map[string]time.Duration{
+					"security_critical":  7 * 24 * time.Hour,
+					"security_important": 30 * 24 * time.Hour,
+					"bugfix":             90 * 24 * time.Hour,
+				},
+			}
+			if fn := fixtureConfig[name]; fn != nil {
+				fn(&cfg)
+			}
+
+			if code := runOnce(cfg, run, filepath.Join(fixtures, name, "root")); code != 0 {
+				t.Fatalf("runOnce = %d", code)
+			}
+			b, err := os.ReadFile(cfg.TextfilePath())
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := normalize(string(b), out)
+
+			golden := filepath.Join("testdata", "golden", name+".prom")
+			if *update {
+				if err := os.WriteFile(golden, []byte(got), 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("%v (run with -update to create it)", err)
+			}
+			if got != string(want) {
+				t.Errorf("textfile differs from %s (run with -update to accept):\n%s", golden, diffLines(string(want), got))
+			}
+		})
+	}
+}
+
+// volatile matches the samples of durations and of timestamps taken from the
+// clock of the run.
+var volatile = regexp.MustCompile(`(?m)^(\w+_duration_seconds(?:\{[^}]*\})?|os_updates_last_run_timestamp_seconds|os_pending_cve_first_seen_timestamp_seconds\{[^}]*\}) \S+$`)
+
+// normalize zeroes durations and run timestamps and replaces the temporary output
+// directory so the textfile is stable between runs.
+func normalize(s, outDir string) string {
+	s = volatile.ReplaceAllString(s, "$1 0")
+	return strings.ReplaceAll(s, outDir, "TEXTFILE_DIR")
+}
+
+// diffLines lists the lines missing from got ("-") and the unexpected ones ("+").
+func diffLines(want, got string) string {
+	count := func(s string) map[string]int {
+		m := map[string]int{}
+		for _, ln := range strings.Split(s, "\n") {
+			m[ln]++
+		}
+		return m
+	}
+	w, g := count(want), count(got)
+	var b strings.Builder
+	for _, ln := range strings.Split(want, "\n") {
+		if g[ln] > 0 {
+			g[ln]--
+			continue
+		}
+		b.WriteString("- " + ln + "\n")
+	}
+	for _, ln := range strings.Split(got, "\n") {
+		if w[ln] > 0 {
+			w[ln]--
+			continue
+		}
+		b.WriteString("+ " + ln + "\n")
+	}
+	return b.String()
+}
+
+func TestExplain(t *testing.T) {
+	run, err := runnertest.Load(filepath.Join(fixtures, "rhel-9", "commands.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := t.TempDir()
+	model := filepath.Join(out, "risk.txt")
+	if err := os.WriteFile(model, []byte("security_important 5 8\nreboot 2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.Config{
+		StateFile:     filepath.Join(out, "state.json"),
+		LockFile:      filepath.Join(out, "os-updates-exporter.lock"),
+		PkgmgrTimeout: 10 * time.Second,
+		OfflineMode:   true,
+		RiskModelFile: model,
+	}
+	var b strings.Builder
+	if code := runExplain(cfg, run, filepath.Join(fixtures, "rhel-9", "root"), &b); code != 0 {
+		t.Fatalf("runExplain = %d", code)
+	}
+	// rhel-9: 2 bugfix, important 2 (capped), moderate 2, low 1 and
+	// 5 security updates with a kernel reboot pending
+	for _, want := range []string{
+		"dnf    security_important  2      5       8    8\n",
+		"dnf    score                                   42\n",
+		"host   reboot              1      2       -    2\n",
+		"total                                          44\n",
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("missing %q in\n%s", want, b.String())
+		}
+	}
+}
+
+func TestEvaluateSLA(t *testing.T) {
+	const day = 86400
+	now := int64(100 * day)
+	res := collector.Result{Managers: []collector.ManagerResult{{
+		Manager: "dnf",
+		Packages: []collector.Package{
+			{Name: "openssl", Arch: "x86_64", InstalledVersion: "1:3.0.7-27.el9", Class: "security", Severity: "critical"},
+			{Name: "kernel", Arch: "x86_64", InstalledVersion: "5.14.0-427.28.1.el9_4", Class: "security", Severity: "Critical"},
+			{Name: "glibc", Arch: "x86_64", InstalledVersion: "2.34-100.el9", Class: "security", Severity: "moderate"},
+			{Name: "tzdata", Arch: "noarch", InstalledVersion: "2024a-1.el9", Class: "bugfix", Held: true, State: "held"},
+			{Name: "bash", Arch: "x86_64", InstalledVersion: "5.1.8-9.el9", Class: "bugfix"},
+		},
+	}}}
+	st := state.New()
+	st.PendingPackages = map[string]map[string]state.PendingPackage{"dnf": {
+		"openssl.x86_64 1:3.0.7-27.el9":       {Class: "security", FirstSeen: now - 8*day},
+		"kernel.x86_64 5.14.0-427.28.1.el9_4": {Class: "security", FirstSeen: now - 5*day},
+		"tzdata.noarch 2024a-1.el9":           {Class: "bugfix", FirstSeen: now - 95*day},
+		// bash is not tracked yet: first seen now
+	}}
+	cfg := config.Config{SLA: map[string]time.Duration{
+		"security_critical":  7 * 24 * time.Hour,
+		"security_important": 30 * 24 * time.Hour,
+		"bugfix":             90 * 24 * time.Hour,
+	}}
+	want := map[string]slaStatus{
+		// openssl breached a day ago
+		"security_critical":  {Breached: 1, Remaining: -1 * day},
+		"security_important": {Remaining: 30 * day},
+		// the held tzdata update is left out
+		"bugfix": {Remaining: 90 * day},
+	}
+	if got := evaluateSLA(st, res, cfg, now); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	cfg.ComplianceIncludeHeld = true
+	if got := evaluateSLA(st, res, cfg, now)["bugfix"]; got.Breached != 1 || got.Remaining != -5*day {
+		t.Errorf("bugfix with held updates = %+v", got)
+	}
+}
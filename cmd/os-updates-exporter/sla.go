@@ -0,0 +1,55 @@
+package main
+
+import (
+	"github.com/R4VXN/os-updates-exporter/internal/collector"
+	"github.com/R4VXN/os-updates-exporter/internal/config"
+	"github.com/R4VXN/os-updates-exporter/internal/state"
+)
+
+// slaStatus is the patch SLA compliance of one SLA class.
+type slaStatus struct {
+	// Breached counts the pending updates past their deadline.
+	Breached int
+	// Remaining is the time until the next pending update breaches its SLA
+	// (seconds). It is negative once one has: how long ago the most overdue
+	// update breached. With no pending update in the class it is the full SLA.
+	Remaining float64
+}
+
+// evaluateSLA checks the pending updates of res against cfg.SLA, using the
+// per-package first-seen times in st (updated by trackAges). Like the
+// effective compliance it is given the OS package managers only
+// (Result.OSPackages) and leaves out phased updates, and held ones unless
+// cfg.ComplianceIncludeHeld is set.
+func evaluateSLA(st *state.State, res collector.Result, cfg config.Config, now int64) map[string]slaStatus {
+	out := map[string]slaStatus{}
+	for class, d := range cfg.SLA {
+		out[class] = slaStatus{Remaining: d.Seconds()}
+	}
+	for _, mr := range res.Managers {
+		tracked := st.PendingPackages[mr.Manager]
+		for _, p := range mr.Packages {
+			if p.State == "phased" || (p.Held && !cfg.ComplianceIncludeHeld) {
+				continue
+			}
+			class := p.SLAClass()
+			s, ok := out[class]
+			if !ok {
+				continue
+			}
+			firstSeen := now
+			if pp, ok := tracked[packageKey(p)]; ok {
+				firstSeen = pp.FirstSeen
+			}
+			left := cfg.SLA[class].Seconds() - float64(now-firstSeen)
+			if left <= 0 {
+				s.Breached++
+			}
+			if left < s.Remaining {
+				s.Remaining = left
+			}
+			out[class] = s
+		}
+	}
+	return out
+}
@@ -1,3 +1,5 @@
 module github.com/R4VXN/os-updates-exporter
 
 go 1.22
+
+require github.com/pierrec/lz4/v4 v4.1.31
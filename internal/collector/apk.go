@@ -0,0 +1,150 @@
+package collector
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/R4VXN/os-updates-exporter/internal/runner"
+)
+
+type apkBackend struct{}
+
+func init() { Register(apkBackend{}) }
+
+func (apkBackend) Name() string { return "apk" }
+
+func (apkBackend) Detect(env *Env) bool { return env.available("apk", "/lib/apk/db/installed") }
+
+func (apkBackend) CollectPending(ctx context.Context, env *Env) (Pending, error) {
+	all, pkgs, err := collectAPK(ctx, env.Run)
+	// Alpine's secdb is not available locally; everything counts as bugfix.
+	return Pending{All: all, Bugfix: all, Packages: pkgs}, err
+}
+
+func (apkBackend) ListRepos(ctx context.Context, env *Env) []string {
+	return parseApkRepositories(env.Path("/etc/apk/repositories"), apkArch(env))
+}
+
+func (apkBackend) MetadataAge(env *Env) float64 {
+	now := time.Now()
+	maxAge := 0.0
+	matches, _ := filepath.Glob(env.Path("/var/cache/apk/APKINDEX.*"))
+	for _, p := range matches {
+		if st, err := os.Stat(p); err == nil {
+			age := now.Sub(st.ModTime()).Seconds()
+			if age > maxAge {
+				maxAge = age
+			}
+		}
+	}
+	return maxAge
+}
+
+func (apkBackend) RebootHint(ctx context.Context, env *Env) (bool, string) { return false, "unknown" }
+
+func (apkBackend) History(ctx context.Context, env *Env) ([]Upgrade, bool, error) {
+	return nil, false, nil
+}
+
+// Best-effort: apk list -u, falling back to apk version -l '<' on older apk-tools.
+func collectAPK(ctx context.Context, r runner.Runner) (all int, pkgs []Package, err error) {
+	res := r.Run(ctx, "apk", "list", "-u")
+	if res.Check() == nil {
+		pkgs = parseApkListUpgradable(res.Stdout)
+		return len(pkgs), pkgs, nil
+	}
+	res = r.Run(ctx, "apk", "version", "-l", "<")
+	pkgs = parseApkVersion(res.Stdout)
+	return len(pkgs), pkgs, res.Check()
+}
+
+// parseApkListUpgradable parses "apk list -u" rows:
+// "busybox-1.36.1-r5 x86_64 {busybox} (GPL-2.0-only) [upgradable from: busybox-1.36.1-r2]".
+func parseApkListUpgradable(out string) []Package {
+	pkgs := []Package{}
+	for _, ln := range strings.Split(out, "\n") {
+		fields := strings.Fields(ln)
+		if len(fields) < 2 || !strings.Contains(ln, "upgradable from:") {
+			continue
+		}
+		name, candidate := splitApkPkgVer(fields[0])
+		if name == "" {
+			continue
+		}
+		p := Package{Name: name, Arch: fields[1], CandidateVersion: candidate, Class: "bugfix"}
+		from := ln[strings.Index(ln, "upgradable from:")+len("upgradable from:"):]
+		from = strings.TrimSuffix(strings.TrimSpace(from), "]")
+		if _, v := splitApkPkgVer(from); v != "" {
+			p.InstalledVersion = v
+		}
+		pkgs = append(pkgs, p)
+	}
+	return pkgs
+}
+
+// parseApkVersion parses "apk version -l '<'" rows: "busybox-1.36.1-r2   < 1.36.1-r5".
+func parseApkVersion(out string) []Package {
+	pkgs := []Package{}
+	for _, ln := range strings.Split(out, "\n") {
+		fields := strings.Fields(ln)
+		if len(fields) != 3 || fields[1] != "<" {
+			continue
+		}
+		name, installed := splitApkPkgVer(fields[0])
+		if name == "" {
+			continue
+		}
+		pkgs = append(pkgs, Package{Name: name, InstalledVersion: installed, CandidateVersion: fields[2], Class: "bugfix"})
+	}
+	return pkgs
+}
+
+// splitApkPkgVer splits "name-version-rN" into name and "version-rN".
+func splitApkPkgVer(s string) (name, version string) {
+	r := strings.LastIndex(s, "-")
+	if r <= 0 {
+		return "", ""
+	}
+	v := strings.LastIndex(s[:r], "-")
+	if v <= 0 {
+		return "", ""
+	}
+	return s[:v], s[v+1:]
+}
+
+// parseApkRepositories returns the APKINDEX URL of every http(s) repository
+// in /etc/apk/repositories ("[@tag ]url" per line).
+func parseApkRepositories(path, arch string) []string {
+	out := []string{}
+	fd, err := os.Open(path)
+	if err != nil {
+		return out
+	}
+	defer fd.Close()
+	sc := bufio.NewScanner(fd)
+	for sc.Scan() {
+		ln := strings.TrimSpace(sc.Text())
+		if ln == "" || strings.HasPrefix(ln, "#") {
+			continue
+		}
+		fields := strings.Fields(ln)
+		u := fields[len(fields)-1]
+		if strings.HasPrefix(u, "http://") || strings.HasPrefix(u, "https://") {
+			out = append(out, strings.TrimSuffix(u, "/")+"/"+arch+"/APKINDEX.tar.gz")
+		}
+	}
+	return out
+}
+
+func apkArch(env *Env) string {
+	if b, err := os.ReadFile(env.Path("/etc/apk/arch")); err == nil {
+		if a := strings.TrimSpace(string(b)); a != "" {
+			return a
+		}
+	}
+	return linuxArch()
+}
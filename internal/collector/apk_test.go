@@ -0,0 +1,71 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseApkListUpgradable(t *testing.T) {
+	out := `busybox-1.36.1-r29 x86_64 {busybox} (GPL-2.0-only) [upgradable from: busybox-1.36.1-r28]
+py3-setuptools-70.3.0-r0 noarch {py3-setuptools} (MIT) [upgradable from: py3-setuptools-70.1.0-r0]
+ca-certificates-bundle-20240705-r0 x86_64 {ca-certificates} (MPL-2.0 AND MIT) [installed]
+`
+	want := []Package{
+		{Name: "busybox", Arch: "x86_64", InstalledVersion: "1.36.1-r28", CandidateVersion: "1.36.1-r29", Class: "bugfix"},
+		{Name: "py3-setuptools", Arch: "noarch", InstalledVersion: "70.1.0-r0", CandidateVersion: "70.3.0-r0", Class: "bugfix"},
+	}
+	if got := parseApkListUpgradable(out); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestParseApkVersion(t *testing.T) {
+	out := `Installed:                                Available:
+busybox-1.36.1-r28                      < 1.36.1-r29
+libcrypto3-3.3.1-r3                     < 3.3.2-r0
+musl-1.2.5-r0                           = 1.2.5-r0
+`
+	want := []Package{
+		{Name: "busybox", InstalledVersion: "1.36.1-r28", CandidateVersion: "1.36.1-r29", Class: "bugfix"},
+		{Name: "libcrypto3", InstalledVersion: "3.3.1-r3", CandidateVersion: "3.3.2-r0", Class: "bugfix"},
+	}
+	if got := parseApkVersion(out); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestSplitApkPkgVer(t *testing.T) {
+	for _, tc := range []struct{ in, name, version string }{
+		{"busybox-1.36.1-r29", "busybox", "1.36.1-r29"},
+		{"py3-setuptools-70.3.0-r0", "py3-setuptools", "70.3.0-r0"},
+		{"busybox-r29", "", ""},
+		{"busybox", "", ""},
+	} {
+		if name, version := splitApkPkgVer(tc.in); name != tc.name || version != tc.version {
+			t.Errorf("splitApkPkgVer(%q) = %q, %q, want %q, %q", tc.in, name, version, tc.name, tc.version)
+		}
+	}
+}
+
+func TestParseApkRepositories(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "repositories")
+	if err := os.WriteFile(path, []byte(`https://dl-cdn.alpinelinux.org/alpine/v3.20/main
+# https://dl-cdn.alpinelinux.org/alpine/v3.20/community
+@edge https://dl-cdn.alpinelinux.org/alpine/edge/testing/
+/media/cdrom/apks
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"https://dl-cdn.alpinelinux.org/alpine/v3.20/main/x86_64/APKINDEX.tar.gz",
+		"https://dl-cdn.alpinelinux.org/alpine/edge/testing/x86_64/APKINDEX.tar.gz",
+	}
+	if got := parseApkRepositories(path, "x86_64"); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got := parseApkRepositories(filepath.Join(t.TempDir(), "missing"), "x86_64"); len(got) != 0 {
+		t.Errorf("missing file: got %q", got)
+	}
+}
@@ -2,16 +2,94 @@ package collector
 
 import (
 	"context"
+	"errors"
+	"os"
+	"path/filepath"
 	"regexp"
 	"strings"
+	"time"
+
+	"github.com/R4VXN/os-updates-exporter/internal/runner"
 )
 
-// Best-effort: count apt list --upgradable entries. Security split: origin contains "security".
-func collectAPT(ctx context.Context) (all, sec, bug int, err error) {
-	out, err := runCmd(ctx, "bash", "-lc", `LANG=C apt list --upgradable 2>/dev/null || true`)
-	lines := strings.Split(out, "\n")
+type aptBackend struct{}
+
+func init() { Register(aptBackend{}) }
+
+func (aptBackend) Name() string { return "apt" }
+
+func (aptBackend) Detect(env *Env) bool {
+	if env.Cfg.AptNative && !env.Live() {
+		// no apt binary needed in the exporter's environment
+		_, err := os.Stat(env.Path("/var/lib/dpkg/status"))
+		return err == nil
+	}
+	return env.available("apt-get", "/var/lib/dpkg/status") || env.available("apt", "/var/lib/dpkg/status")
+}
+
+func (aptBackend) CollectPending(ctx context.Context, env *Env) (Pending, error) {
+	var (
+		p   Pending
+		err error
+	)
+	if env.Cfg.AptNative {
+		p, err = collectAPTNative(defaultAptPaths(env))
+	} else {
+		p, err = collectAPT(ctx, env.Run, defaultAptPaths(env))
+	}
+	p.CVEs = aptCachedCVEs(ctx, env.Run, env.Path("/var/cache/apt/archives"), p.Packages)
+	return p, err
+}
+
+func (aptBackend) ListRepos(ctx context.Context, env *Env) []string { return parseAptSources(env) }
+
+func (aptBackend) MetadataAge(env *Env) float64 {
+	now := time.Now()
+	maxAge := 0.0
+	matches, _ := filepath.Glob(env.Path("/var/lib/apt/lists/*Release"))
+	for _, p := range matches {
+		if st, err := os.Stat(p); err == nil {
+			age := now.Sub(st.ModTime()).Seconds()
+			if age > maxAge {
+				maxAge = age
+			}
+		}
+	}
+	return maxAge
+}
+
+// RebootHint: Debian/Ubuntu signal via /var/run/reboot-required is handled by reboot.Detect.
+func (aptBackend) RebootHint(ctx context.Context, env *Env) (bool, string) { return false, "unknown" }
+
+func (aptBackend) History(ctx context.Context, env *Env) ([]Upgrade, bool, error) {
+	ups, err := aptHistory(env)
+	return ups, true, err
+}
+
+// Best-effort: count apt list --upgradable entries. Security split: the candidate is
+// available from a security archive according to the Release files in lists.
+// Held packages come from apt-mark showhold and from pins with a negative
+// priority, kept-back and phased updates from a simulated apt-get dist-upgrade:
+// updates it keeps back, and updates that need one of the new packages it
+// installs (apt-get upgrade keeps those back), are kept back.
+func collectAPT(ctx context.Context, r runner.Runner, paths aptPaths) (Pending, error) {
+	p := Pending{}
+	res := r.Run(ctx, "apt", "list", "--upgradable")
+	suites := aptSecuritySuites(paths.Lists)
+
+	held := map[string]bool{}
+	holdRes := r.Run(ctx, "apt-mark", "showhold")
+	for _, name := range strings.Fields(holdRes.Stdout) {
+		held[name] = true
+		p.Held = append(p.Held, name)
+	}
+
+	// if the simulation fails, the states of all updates are unknown
+	sim := r.Run(ctx, "apt-get", "-s", "-o", "Debug::NoLocking=1", "dist-upgrade")
+	keptBack, phased, newPkgs := parseAptGetUpgradeSim(sim.Stdout)
+
 	re := regexp.MustCompile(`^[^/]+/`)
-	for _, ln := range lines {
+	for _, ln := range strings.Split(res.Stdout, "\n") {
 		ln = strings.TrimSpace(ln)
 		if ln == "" || strings.HasPrefix(ln, "Listing...") {
 			continue
@@ -19,13 +97,129 @@ func collectAPT(ctx context.Context) (all, sec, bug int, err error) {
 		if !re.MatchString(ln) {
 			continue
 		}
-		all++
-		l := strings.ToLower(ln)
-		if strings.Contains(l, "security") {
-			sec++
+		p.All++
+		pkg := parseAptUpgradable(ln)
+		if aptIsSecurity(pkg.Repo, suites) {
+			p.Security++
+			pkg.Class = "security"
+		} else {
+			p.Bugfix++
+			pkg.Class = "bugfix"
+		}
+		pkg.Held = held[pkg.Name] || held[pkg.Name+":"+pkg.Arch]
+		p.Packages = append(p.Packages, pkg)
+	}
+
+	var listErr error
+	needsNew := map[string]bool{}
+	if len(newPkgs) > 0 {
+		needsNew, listErr = aptNeedsNew(p.Packages, newPkgs, paths.Lists)
+	}
+	for i := range p.Packages {
+		pkg := &p.Packages[i]
+		switch {
+		case phased[pkg.Name] || phased[pkg.Name+":"+pkg.Arch]:
+			pkg.State = "phased"
+		case keptBack[pkg.Name] || keptBack[pkg.Name+":"+pkg.Arch] || needsNew[pkg.Name]:
+			pkg.State = "kept_back"
+		default:
+			pkg.State = "installable"
+		}
+	}
+
+	pinned, pinErr := aptPinnedAwayUpdates(paths)
+	for _, pkg := range pinned {
+		if !held[pkg.Name] {
+			p.Held = append(p.Held, pkg.Name)
+		}
+		p.All++
+		if pkg.Class == "security" {
+			p.Security++
 		} else {
-			bug++
+			p.Bugfix++
+		}
+		p.Packages = append(p.Packages, pkg)
+	}
+	return p, errors.Join(res.Check(), holdRes.Check(), sim.Check(), listErr, pinErr)
+}
+
+// parseAptUpgradable parses one "apt list --upgradable" line, e.g.
+// "bash/jammy-updates,jammy-security 5.1-6ubuntu1.1 amd64 [upgradable from: 5.1-6ubuntu1]".
+func parseAptUpgradable(ln string) Package {
+	p := Package{}
+	fields := strings.Fields(ln)
+	if len(fields) == 0 {
+		return p
+	}
+	p.Name, p.Repo, _ = strings.Cut(fields[0], "/")
+	if len(fields) > 1 {
+		p.CandidateVersion = fields[1]
+	}
+	if len(fields) > 2 {
+		p.Arch = fields[2]
+	}
+	if i := strings.Index(ln, "upgradable from:"); i >= 0 {
+		p.InstalledVersion = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(ln[i+len("upgradable from:"):]), "]"))
+	}
+	return p
+}
+
+// parseAptGetUpgradeSim returns the packages listed as kept back, as deferred
+// due to phasing and as new in "apt-get -s dist-upgrade" output.
+func parseAptGetUpgradeSim(out string) (keptBack, phased, newPkgs map[string]bool) {
+	keptBack, phased, newPkgs = map[string]bool{}, map[string]bool{}, map[string]bool{}
+	var cur map[string]bool
+	for _, ln := range strings.Split(out, "\n") {
+		switch {
+		case strings.HasPrefix(ln, "The following packages have been kept back"):
+			cur = keptBack
+		case strings.HasPrefix(ln, "The following upgrades have been deferred due to phasing"):
+			cur = phased
+		case strings.HasPrefix(ln, "The following NEW packages will be installed"):
+			cur = newPkgs
+		case strings.HasPrefix(ln, " ") && cur != nil:
+			for _, name := range strings.Fields(ln) {
+				cur[name] = true
+			}
+		default:
+			cur = nil
+		}
+	}
+	return keptBack, phased, newPkgs
+}
+
+// aptNeedsNew returns the updates whose candidate depends on one of newPkgs, or
+// on another update that does. The dependencies are read from the apt lists.
+func aptNeedsNew(pkgs []Package, newPkgs map[string]bool, lists string) (map[string]bool, error) {
+	names := map[string]bool{}
+	for _, pkg := range pkgs {
+		names[pkg.Name] = true
+	}
+	avail, err := readAptIndexes(lists, names)
+	deps := map[string][][]string{}
+	for _, pkg := range pkgs {
+		for _, a := range avail[pkg.Name+":"+pkg.Arch] {
+			if a.Version == pkg.CandidateVersion {
+				deps[pkg.Name] = aptDepends(a.Depends)
+			}
+		}
+	}
+	needs := map[string]bool{}
+	for changed := true; changed; {
+		changed = false
+		for name, groups := range deps {
+			if needs[name] {
+				continue
+			}
+			for _, alts := range groups {
+				for _, alt := range alts {
+					if newPkgs[alt] || needs[alt] {
+						needs[name] = true
+						changed = true
+					}
+				}
+			}
 		}
 	}
-	return all, sec, bug, err
+	return needs, err
 }
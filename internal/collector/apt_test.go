@@ -0,0 +1,156 @@
+package collector
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/R4VXN/os-updates-exporter/internal/runner/runnertest"
+)
+
+func TestParseAptUpgradable(t *testing.T) {
+	for _, tc := range []struct {
+		line string
+		want Package
+	}{
+		{
+			line: "bash/jammy-updates,jammy-security 5.1-6ubuntu1.1 amd64 [upgradable from: 5.1-6ubuntu1]",
+			want: Package{Name: "bash", Repo: "jammy-updates,jammy-security", CandidateVersion: "5.1-6ubuntu1.1", Arch: "amd64", InstalledVersion: "5.1-6ubuntu1"},
+		},
+		{
+			line: "tzdata/stable-updates 2024a-0+deb12u1 all [upgradable from: 2023c-5+deb12u1]",
+			want: Package{Name: "tzdata", Repo: "stable-updates", CandidateVersion: "2024a-0+deb12u1", Arch: "all", InstalledVersion: "2023c-5+deb12u1"},
+		},
+		{
+			// epochs contain a colon
+			line: "libpam0g/noble-updates 1:1.5.3-5ubuntu5.1 amd64 [upgradable from: 1:1.5.3-5ubuntu5]",
+			want: Package{Name: "libpam0g", Repo: "noble-updates", CandidateVersion: "1:1.5.3-5ubuntu5.1", Arch: "amd64", InstalledVersion: "1:1.5.3-5ubuntu5"},
+		},
+	} {
+		if got := parseAptUpgradable(tc.line); got != tc.want {
+			t.Errorf("parseAptUpgradable(%q) = %+v, want %+v", tc.line, got, tc.want)
+		}
+	}
+}
+
+func TestParseAptGetUpgradeSim(t *testing.T) {
+	out := `Reading package lists...
+Calculating upgrade...
+The following NEW packages will be installed:
+  linux-headers-5.15.0-122 linux-headers-5.15.0-122-generic
+  linux-image-5.15.0-122-generic
+The following packages have been kept back:
+  cockpit
+The following upgrades have been deferred due to phasing:
+  libsystemd0:amd64 systemd
+The following packages will be upgraded:
+  linux-generic python3.10 redis-server
+3 upgraded, 3 newly installed, 0 to remove and 3 not upgraded.
+Inst python3.10 [3.10.12-1~22.04.4] (3.10.12-1~22.04.5 Ubuntu:22.04/jammy-updates [amd64])
+`
+	keptBack, phased, newPkgs := parseAptGetUpgradeSim(out)
+	wantNew := map[string]bool{"linux-headers-5.15.0-122": true, "linux-headers-5.15.0-122-generic": true, "linux-image-5.15.0-122-generic": true}
+	wantPhased := map[string]bool{"libsystemd0:amd64": true, "systemd": true}
+	if !reflect.DeepEqual(keptBack, map[string]bool{"cockpit": true}) {
+		t.Errorf("kept back = %v", keptBack)
+	}
+	if !reflect.DeepEqual(phased, wantPhased) {
+		t.Errorf("phased = %v, want %v", phased, wantPhased)
+	}
+	if !reflect.DeepEqual(newPkgs, wantNew) {
+		t.Errorf("new = %v, want %v", newPkgs, wantNew)
+	}
+}
+
+func TestCollectAPTStates(t *testing.T) {
+	run, err := runnertest.Parse(`$ apt list --upgradable
+Listing...
+cockpit/jammy-backports 322-1~bpo22.04.1 all [upgradable from: 320-1~bpo22.04.1]
+libsystemd0/jammy-updates 249.11-0ubuntu3.12 amd64 [upgradable from: 249.11-0ubuntu3.11]
+linux-generic/jammy-updates 5.15.0.122.122 amd64 [upgradable from: 5.15.0.119.119]
+linux-image-generic/jammy-updates 5.15.0.122.122 amd64 [upgradable from: 5.15.0.119.119]
+python3.10/jammy-updates 3.10.12-1~22.04.5 amd64 [upgradable from: 3.10.12-1~22.04.4]
+redis-server/jammy-updates 5:6.0.16-1ubuntu1.1 amd64 [upgradable from: 5:6.0.16-1ubuntu1]
+$ apt-mark showhold
+redis-server
+$ apt-get -s -o Debug::NoLocking=1 dist-upgrade
+The following NEW packages will be installed:
+  linux-image-5.15.0-122-generic
+The following packages have been kept back:
+  cockpit
+The following upgrades have been deferred due to phasing:
+  libsystemd0:amd64
+The following packages will be upgraded:
+  linux-generic linux-image-generic python3.10
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lists := t.TempDir()
+	if err := os.WriteFile(filepath.Join(lists, "archive.ubuntu.com_ubuntu_dists_jammy-updates_main_binary-amd64_Packages"), []byte(`Package: linux-generic
+Architecture: amd64
+Version: 5.15.0.122.122
+Depends: linux-image-generic (= 5.15.0.122.122)
+
+Package: linux-image-generic
+Architecture: amd64
+Version: 5.15.0.122.122
+Depends: linux-image-5.15.0-122-generic, linux-firmware
+
+Package: python3.10
+Architecture: amd64
+Version: 3.10.12-1~22.04.5
+Depends: python3.10-minimal (= 3.10.12-1~22.04.5), media-types | mime-support
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := collectAPT(context.Background(), run, aptPaths{Lists: lists})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]string{}
+	for _, pkg := range p.Packages {
+		got[pkg.Name] = pkg.State
+		if pkg.Held != (pkg.Name == "redis-server") {
+			t.Errorf("%s: held %v", pkg.Name, pkg.Held)
+		}
+	}
+	// linux-image-generic needs a new package, linux-generic needs
+	// linux-image-generic: apt-get upgrade keeps both back. A held package is
+	// not in the simulation; collectBackend sets its state to held.
+	want := map[string]string{
+		"cockpit":             "kept_back",
+		"libsystemd0":         "phased",
+		"linux-generic":       "kept_back",
+		"linux-image-generic": "kept_back",
+		"python3.10":          "installable",
+		"redis-server":        "installable",
+	}
+	if p.All != 6 || !reflect.DeepEqual(got, want) {
+		t.Errorf("%d updates, states %v, want %v", p.All, got, want)
+	}
+}
+
+func TestCollectAPTFailedSimulation(t *testing.T) {
+	run, err := runnertest.Parse(`$ apt list --upgradable
+Listing...
+linux-generic/jammy-updates 5.15.0.122.122 amd64 [upgradable from: 5.15.0.119.119]
+$ apt-mark showhold
+$ apt-get -s -o Debug::NoLocking=1 dist-upgrade
+? 100
+! E: dpkg was interrupted, you must manually run 'dpkg --configure -a' to correct the problem.
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := collectAPT(context.Background(), run, aptPaths{Lists: t.TempDir()})
+	if err == nil || !strings.Contains(err.Error(), "dpkg was interrupted") {
+		t.Errorf("err = %v, want the simulation error", err)
+	}
+	if p.All != 1 {
+		t.Errorf("%d updates, want 1", p.All)
+	}
+}
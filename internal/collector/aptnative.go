@@ -0,0 +1,589 @@
+package collector
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/R4VXN/os-updates-exporter/internal/version"
+	"github.com/pierrec/lz4/v4"
+)
+
+// Native apt collection: reads /var/lib/dpkg/status and the Packages indexes in
+// /var/lib/apt/lists directly and selects candidates like apt's policy engine
+// (pin priorities, NotAutomatic archives). No subprocess, no apt lock.
+
+// aptPaths are the files read by the native collector.
+type aptPaths struct {
+	Status      string
+	Lists       string
+	Preferences []string
+	MachineID   string
+}
+
+func defaultAptPaths(env *Env) aptPaths {
+	prefs := []string{env.Path("/etc/apt/preferences")}
+	files, _ := filepath.Glob(env.Path("/etc/apt/preferences.d/*"))
+	for _, f := range files {
+		// apt ignores files with an extension other than .pref
+		if ext := filepath.Ext(f); ext == "" || ext == ".pref" {
+			prefs = append(prefs, f)
+		}
+	}
+	return aptPaths{
+		Status:      env.Path("/var/lib/dpkg/status"),
+		Lists:       env.Path("/var/lib/apt/lists"),
+		Preferences: prefs,
+		MachineID:   env.Path("/etc/machine-id"),
+	}
+}
+
+// debInstalled is an installed package from the dpkg status file.
+type debInstalled struct {
+	Name     string
+	Arch     string
+	Version  string
+	Hold     bool // dpkg selection is "hold" (apt-mark hold)
+	Provides string
+	// Source and SourceVersion name the source package (vulnerability feeds).
+	Source        string
+	SourceVersion string
+}
+
+// aptIndex is one Packages file with the attributes pins are matched against.
+type aptIndex struct {
+	Release   aptRelease
+	Component string
+	Site      string
+	Priority  int
+}
+
+// aptAvail is a version of a package available from an index.
+type aptAvail struct {
+	Version string
+	Index   *aptIndex
+	// Source and SourceVersion seed the phased update selection.
+	Source        string
+	SourceVersion string
+	Phased        int // Phased-Update-Percentage, 100 if unset
+	Depends       string
+}
+
+// aptPin is a stanza of apt_preferences(5).
+type aptPin struct {
+	Packages []string
+	Pin      string
+	Priority int
+}
+
+// collectAPTNative computes the pending updates from dpkg status and the apt
+// lists. Packages on dpkg hold are reported as held; newer versions pinned away
+// with a negative priority are reported as held pending updates. Candidates
+// that need a package which is not installed are kept back (apt-get upgrade
+// does not install new packages), candidates outside this machine's phased
+// update percentage are deferred. An index that cannot be read (for example an
+// unsupported compression) is an error; the other indexes are still counted.
+func collectAPTNative(paths aptPaths) (Pending, error) {
+	p := Pending{}
+	installed, err := readDpkgStatus(paths.Status)
+	if err != nil {
+		return p, err
+	}
+	names := map[string]bool{}
+	provided := map[string]bool{}
+	for _, in := range installed {
+		names[in.Name] = true
+		provided[in.Name] = true
+		for _, dep := range aptDepends(in.Provides) {
+			provided[dep[0]] = true
+		}
+	}
+	avail, listErr := readAptIndexes(paths.Lists, names)
+	pins := readAptPreferences(paths.Preferences)
+	machineID := readMachineID(paths.MachineID)
+
+	for _, in := range installed {
+		versions := avail[in.Name+":"+in.Arch]
+		prio, from := aptPriorities(in, versions, pins)
+		cand := aptCandidate(in, prio)
+		held := in.Hold
+		if version.CompareDeb(cand, in.Version) <= 0 {
+			if cand = aptPinnedAway(in, prio); cand == "" {
+				if held {
+					p.Held = append(p.Held, in.Name)
+				}
+				continue
+			}
+			held = true
+		}
+		if held {
+			p.Held = append(p.Held, in.Name)
+		}
+		pkg := aptPendingPackage(in, cand, from[cand])
+		pkg.Held = held
+		pkg.State = "installable"
+		for _, a := range versions {
+			if a.Version != cand {
+				continue
+			}
+			switch {
+			case pkg.Class != "security" && aptPhasedDeferred(a, machineID):
+				pkg.State = "phased"
+			case !aptDependsSatisfied(a.Depends, provided):
+				pkg.State = "kept_back"
+			}
+			break
+		}
+		p.All++
+		if pkg.Class == "security" {
+			p.Security++
+		} else {
+			p.Bugfix++
+		}
+		p.Packages = append(p.Packages, pkg)
+	}
+	sort.Slice(p.Packages, func(i, j int) bool { return p.Packages[i].Name < p.Packages[j].Name })
+	return p, listErr
+}
+
+// aptPendingPackage returns the update of in to cand, classified by the
+// indexes cand is available from.
+func aptPendingPackage(in debInstalled, cand string, from []*aptIndex) Package {
+	pkg := Package{Name: in.Name, Arch: in.Arch, InstalledVersion: in.Version, CandidateVersion: cand, Class: "bugfix"}
+	suites := []string{}
+	for _, x := range from {
+		if x.Release.security() {
+			pkg.Class = "security"
+		}
+		if x.Release.Suite != "" {
+			suites = append(suites, x.Release.Suite)
+		}
+	}
+	pkg.Repo = strings.Join(unique(suites), ",")
+	return pkg
+}
+
+// aptPinnedAwayUpdates returns the updates of installed packages whose newer
+// versions are all excluded by a negative pin priority, marked as held. Their
+// candidate is the installed version, so "apt list --upgradable" does not list
+// them. Only packages matched by a negative pin are looked up in the indexes.
+func aptPinnedAwayUpdates(paths aptPaths) ([]Package, error) {
+	pins := readAptPreferences(paths.Preferences)
+	negative := []aptPin{}
+	for _, pin := range pins {
+		if pin.Priority < 0 {
+			negative = append(negative, pin)
+		}
+	}
+	if len(negative) == 0 {
+		return nil, nil
+	}
+	installed, err := readDpkgStatus(paths.Status)
+	if err != nil {
+		return nil, err
+	}
+	names := map[string]bool{}
+	for _, in := range installed {
+		for _, pin := range negative {
+			if pin.matchesPackage(in.Name) {
+				names[in.Name] = true
+				break
+			}
+		}
+	}
+	if len(names) == 0 {
+		return nil, nil
+	}
+	avail, listErr := readAptIndexes(paths.Lists, names)
+	pkgs := []Package{}
+	for _, in := range installed {
+		if !names[in.Name] {
+			continue
+		}
+		prio, from := aptPriorities(in, avail[in.Name+":"+in.Arch], pins)
+		if version.CompareDeb(aptCandidate(in, prio), in.Version) > 0 {
+			// an update that is not pinned away is listed by apt
+			continue
+		}
+		if cand := aptPinnedAway(in, prio); cand != "" {
+			pkg := aptPendingPackage(in, cand, from[cand])
+			pkg.Held = true
+			pkgs = append(pkgs, pkg)
+		}
+	}
+	return pkgs, listErr
+}
+
+// aptPriorities returns the pin priority of every available version of an
+// installed package and the indexes each version is available from.
+func aptPriorities(in debInstalled, avail []aptAvail, pins []aptPin) (map[string]int, map[string][]*aptIndex) {
+	prio := map[string]int{}
+	from := map[string][]*aptIndex{}
+	for _, a := range avail {
+		pr := aptPriority(in.Name, a.Version, a.Index, pins)
+		if cur, ok := prio[a.Version]; !ok || pr > cur {
+			prio[a.Version] = pr
+		}
+		from[a.Version] = append(from[a.Version], a.Index)
+	}
+	return prio, from
+}
+
+// aptCandidate returns the candidate version of an installed package. The
+// highest pin priority wins, ties go to the higher version; downgrades need a
+// priority of at least 1000 and versions with a negative priority are never
+// selected.
+func aptCandidate(in debInstalled, prio map[string]int) string {
+	best := in.Version
+	bestPrio := 100
+	if pr, ok := prio[in.Version]; ok && pr > bestPrio {
+		bestPrio = pr
+	}
+	for v, pr := range prio {
+		if v == in.Version || pr < 0 {
+			continue
+		}
+		if version.CompareDeb(v, in.Version) < 0 && pr < 1000 {
+			continue
+		}
+		if pr > bestPrio || (pr == bestPrio && version.CompareDeb(v, best) > 0) {
+			best, bestPrio = v, pr
+		}
+	}
+	return best
+}
+
+// aptPinnedAway returns the highest version newer than the installed one that
+// is excluded by a negative pin priority, or "".
+func aptPinnedAway(in debInstalled, prio map[string]int) string {
+	best := ""
+	for v, pr := range prio {
+		if pr >= 0 || version.CompareDeb(v, in.Version) <= 0 {
+			continue
+		}
+		if best == "" || version.CompareDeb(v, best) > 0 {
+			best = v
+		}
+	}
+	return best
+}
+
+// aptPriority returns the pin priority of a package version from an index.
+// Package specific pins are checked before general ("Package: *") ones.
+func aptPriority(name, ver string, idx *aptIndex, pins []aptPin) int {
+	for _, specific := range []bool{true, false} {
+		for _, p := range pins {
+			general := len(p.Packages) == 1 && p.Packages[0] == "*"
+			if general == specific {
+				continue
+			}
+			if p.matchesPackage(name) && p.matchesVersion(ver, idx) {
+				return p.Priority
+			}
+		}
+	}
+	return idx.Priority
+}
+
+func (p aptPin) matchesPackage(name string) bool {
+	for _, pat := range p.Packages {
+		if aptPatternMatch(pat, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p aptPin) matchesVersion(ver string, idx *aptIndex) bool {
+	kind, arg, _ := strings.Cut(strings.TrimSpace(p.Pin), " ")
+	arg = strings.TrimSpace(arg)
+	switch kind {
+	case "version":
+		return aptPatternMatch(arg, ver)
+	case "origin":
+		return aptPatternMatch(strings.Trim(arg, `"`), idx.Site)
+	case "release":
+		for _, term := range strings.Split(arg, ",") {
+			key, val, ok := strings.Cut(strings.TrimSpace(term), "=")
+			if !ok {
+				key, val = "a", term
+			}
+			val = strings.Trim(strings.TrimSpace(val), `"`)
+			var field string
+			switch strings.TrimSpace(key) {
+			case "a":
+				field = idx.Release.Suite
+			case "n":
+				field = idx.Release.Codename
+			case "o":
+				field = idx.Release.Origin
+			case "l":
+				field = idx.Release.Label
+			case "c":
+				field = idx.Component
+			default:
+				continue
+			}
+			if !aptPatternMatch(val, field) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// aptPatternMatch matches apt_preferences patterns: exact, glob or /regex/.
+func aptPatternMatch(pat, s string) bool {
+	if pat == "*" {
+		return true
+	}
+	if len(pat) > 1 && strings.HasPrefix(pat, "/") && strings.HasSuffix(pat, "/") {
+		re, err := regexp.Compile(pat[1 : len(pat)-1])
+		return err == nil && re.MatchString(s)
+	}
+	if strings.ContainsAny(pat, "*?[") {
+		ok, _ := path.Match(pat, s)
+		return ok
+	}
+	return pat == s
+}
+
+// readDpkgStatus returns the installed packages of a dpkg status file.
+func readDpkgStatus(file string) ([]debInstalled, error) {
+	fd, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+	out := []debInstalled{}
+	err = readDebStanzas(fd, func(st map[string]string) {
+		status := strings.Fields(st["Status"])
+		if len(status) != 3 || status[2] != "installed" {
+			return
+		}
+		in := debInstalled{Name: st["Package"], Arch: st["Architecture"], Version: st["Version"], Hold: status[0] == "hold", Provides: st["Provides"],
+			Source: st["Package"], SourceVersion: st["Version"]}
+		if src := strings.Fields(st["Source"]); len(src) > 0 {
+			in.Source = src[0]
+			if len(src) > 1 {
+				in.SourceVersion = strings.Trim(src[1], "()")
+			}
+		}
+		out = append(out, in)
+	}, "Package", "Status", "Architecture", "Version", "Provides", "Source")
+	return out, err
+}
+
+// readAptIndexes reads the Packages indexes below dir and returns the versions
+// of the given packages keyed by "name:arch". Indexes that cannot be read are
+// skipped and reported in the error.
+func readAptIndexes(dir string, names map[string]bool) (map[string][]aptAvail, error) {
+	releases := map[string]aptRelease{}
+	relFiles, _ := filepath.Glob(filepath.Join(dir, "*Release"))
+	for _, f := range relFiles {
+		if r, ok := readAptRelease(f); ok {
+			base := filepath.Base(f)
+			prefix := strings.TrimSuffix(strings.TrimSuffix(base, "InRelease"), "Release")
+			releases[prefix] = r
+		}
+	}
+
+	avail := map[string][]aptAvail{}
+	var errs []error
+	files, _ := filepath.Glob(filepath.Join(dir, "*_Packages*"))
+	for _, f := range files {
+		base := filepath.Base(f)
+		if strings.Contains(base, ".diff") {
+			continue
+		}
+		idx := &aptIndex{Priority: 500}
+		prefix := ""
+		for p := range releases {
+			if strings.HasPrefix(base, p) && len(p) > len(prefix) {
+				prefix = p
+			}
+		}
+		if prefix != "" {
+			idx.Release = releases[prefix]
+			idx.Component, _, _ = strings.Cut(strings.TrimPrefix(base, prefix), "_")
+		}
+		idx.Site, _, _ = strings.Cut(base, "_")
+		switch {
+		case idx.Release.NotAutomatic && idx.Release.ButAutomaticUpgrades:
+			idx.Priority = 100
+		case idx.Release.NotAutomatic:
+			idx.Priority = 1
+		}
+
+		r, err := openAptList(f)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		err = readDebStanzas(r, func(st map[string]string) {
+			name := st["Package"]
+			if !names[name] {
+				return
+			}
+			a := aptAvail{Version: st["Version"], Index: idx, Source: name, SourceVersion: st["Version"], Phased: 100}
+			a.Depends = strings.TrimSpace(st["Pre-Depends"] + ", " + st["Depends"])
+			if src := strings.Fields(st["Source"]); len(src) > 0 {
+				// "Source: name" or "Source: name (version)"
+				a.Source = src[0]
+				if len(src) > 1 {
+					a.SourceVersion = strings.Trim(src[1], "()")
+				}
+			}
+			if pct, err := strconv.Atoi(strings.TrimSpace(st["Phased-Update-Percentage"])); err == nil && pct >= 0 && pct <= 100 {
+				a.Phased = pct
+			}
+			avail[name+":"+st["Architecture"]] = append(avail[name+":"+st["Architecture"]], a)
+		}, "Package", "Architecture", "Version", "Source", "Depends", "Pre-Depends", "Phased-Update-Percentage")
+		_ = r.Close()
+		if err != nil {
+			// a corrupt .gz or .lz4 index ends early
+			errs = append(errs, fmt.Errorf("%s: %w", f, err))
+		}
+	}
+	return avail, errors.Join(errs...)
+}
+
+// openAptList opens an index file, transparently decompressing .gz and .lz4
+// (streamed; decoding errors surface while reading).
+// Other compressions (.xz, .zst) are an error rather than an empty index.
+func openAptList(file string) (io.ReadCloser, error) {
+	fd, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	// list names contain the host name, so filepath.Ext does not work here
+	switch {
+	case strings.HasSuffix(file, "_Packages"):
+		return fd, nil
+	case strings.HasSuffix(file, ".gz"):
+		gz, err := gzip.NewReader(fd)
+		if err != nil {
+			_ = fd.Close()
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		return struct {
+			io.Reader
+			io.Closer
+		}{gz, fd}, nil
+	case strings.HasSuffix(file, ".lz4"):
+		return struct {
+			io.Reader
+			io.Closer
+		}{lz4.NewReader(fd), fd}, nil
+	default:
+		_ = fd.Close()
+		return nil, fmt.Errorf("%s: unsupported compression", file)
+	}
+}
+
+// readAptPreferences parses apt_preferences(5) files.
+func readAptPreferences(files []string) []aptPin {
+	pins := []aptPin{}
+	for _, f := range files {
+		fd, err := os.Open(f)
+		if err != nil {
+			continue
+		}
+		_ = readDebStanzas(fd, func(st map[string]string) {
+			pr, err := strconv.Atoi(strings.TrimSpace(st["Pin-Priority"]))
+			if err != nil || st["Package"] == "" || st["Pin"] == "" {
+				return
+			}
+			pins = append(pins, aptPin{Packages: strings.Fields(st["Package"]), Pin: st["Pin"], Priority: pr})
+		}, "Package", "Pin", "Pin-Priority")
+		_ = fd.Close()
+	}
+	return pins
+}
+
+// readDebStanzas calls fn for every RFC822-style stanza in r with the
+// requested fields. Continuation lines and comments are skipped.
+func readDebStanzas(r io.Reader, fn func(map[string]string), fields ...string) error {
+	want := map[string]bool{}
+	for _, f := range fields {
+		want[f] = true
+	}
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	st := map[string]string{}
+	flush := func() {
+		if len(st) > 0 {
+			fn(st)
+			st = map[string]string{}
+		}
+	}
+	for sc.Scan() {
+		ln := sc.Text()
+		if strings.TrimSpace(ln) == "" {
+			flush()
+			continue
+		}
+		if ln[0] == ' ' || ln[0] == '\t' || ln[0] == '#' {
+			continue
+		}
+		key, val, ok := strings.Cut(ln, ":")
+		if !ok || !want[key] {
+			continue
+		}
+		st[key] = strings.TrimSpace(val)
+	}
+	flush()
+	return sc.Err()
+}
+
+// aptDepends splits a Depends/Provides field into its alternative groups of
+// package names; version constraints and architecture qualifiers are dropped.
+func aptDepends(field string) [][]string {
+	groups := [][]string{}
+	for _, g := range strings.Split(field, ",") {
+		alts := []string{}
+		for _, alt := range strings.Split(g, "|") {
+			fields := strings.Fields(alt)
+			if len(fields) == 0 {
+				continue
+			}
+			name, _, _ := strings.Cut(fields[0], ":")
+			name, _, _ = strings.Cut(name, "(")
+			if name != "" {
+				alts = append(alts, name)
+			}
+		}
+		if len(alts) > 0 {
+			groups = append(groups, alts)
+		}
+	}
+	return groups
+}
+
+// aptDependsSatisfied reports whether every dependency group names at least
+// one installed or provided package.
+func aptDependsSatisfied(depends string, provided map[string]bool) bool {
+	for _, alts := range aptDepends(depends) {
+		ok := false
+		for _, name := range alts {
+			if provided[name] {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,231 @@
+package collector
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadAptIndexesUnsupported(t *testing.T) {
+	lists := filepath.Join("testdata", "ubuntu-24.04", "root", "var", "lib", "apt", "lists")
+	dir := t.TempDir()
+	entries, err := os.ReadDir(lists)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		b, err := os.ReadFile(filepath.Join(lists, e.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, e.Name()), b, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	xz := "archive.ubuntu.com_ubuntu_dists_noble-backports_main_binary-amd64_Packages.xz"
+	if err := os.WriteFile(filepath.Join(dir, xz), []byte("\xfd7zXZ\x00"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	avail, err := readAptIndexes(dir, map[string]bool{"libc6": true})
+	if err == nil || !strings.Contains(err.Error(), xz) {
+		t.Errorf("err = %v, want the unsupported %s", err, xz)
+	}
+	if len(avail["libc6:amd64"]) == 0 {
+		t.Error("readable indexes skipped")
+	}
+}
+
+func TestOpenAptListLZ4(t *testing.T) {
+	// testdata/Packages*.lz4 hold a generated Packages index compressed with lz4 v1.9.4:
+	//
+	//	lz4 -9 Packages Packages.lz4
+	//	lz4 -B4 -BD -BX --content-size Packages Packages-linked.lz4
+	for _, name := range []string{"Packages.lz4", "Packages-linked.lz4"} {
+		dir := t.TempDir()
+		b, err := os.ReadFile(filepath.Join("testdata", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// apt names the lists after the URL, so the suffix is not an extension
+		file := filepath.Join(dir, "archive.ubuntu.com_ubuntu_dists_noble_main_binary-amd64_Packages.lz4")
+		if err := os.WriteFile(file, b, 0644); err != nil {
+			t.Fatal(err)
+		}
+		r, err := openAptList(file)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		n := 0
+		err = readDebStanzas(r, func(st map[string]string) {
+			if want := fmt.Sprintf("lib%04d", n); st["Package"] != want {
+				t.Errorf("%s: stanza %d is %q, want %q", name, n, st["Package"], want)
+			}
+			n++
+		}, "Package")
+		_ = r.Close()
+		if err != nil || n != 2000 {
+			t.Errorf("%s: %d stanzas, %v; want 2000", name, n, err)
+		}
+
+		// a truncated list is an error, not a shorter index
+		if err := os.WriteFile(file, b[:len(b)/2], 0644); err != nil {
+			t.Fatal(err)
+		}
+		if r, err = openAptList(file); err == nil {
+			err = readDebStanzas(r, func(map[string]string) {}, "Package")
+			_ = r.Close()
+		}
+		if err == nil {
+			t.Errorf("%s: truncated list read without error", name)
+		}
+	}
+}
+
+func TestAptPhasedDraw(t *testing.T) {
+	// values of dist(rand) in apt's phased update selection (apt-pkg/policy.cc)
+	// built with g++ 12 / libstdc++:
+	//
+	//	std::seed_seq seed(seedStr.begin(), seedStr.end());
+	//	std::minstd_rand rand(seed);
+	//	std::uniform_int_distribution<unsigned int> dist(0, 100);
+	for _, tc := range []struct {
+		source, version, machineID string
+		want                       int
+	}{
+		{"systemd", "255.4-1ubuntu8.4", "4c3a2b1e0f9d8c7b6a5f4e3d2c1b0a99", 19},
+		{"tzdata", "2024a-3ubuntu1.1", "4c3a2b1e0f9d8c7b6a5f4e3d2c1b0a99", 53},
+		{"glibc", "2.39-0ubuntu8.3", "4c3a2b1e0f9d8c7b6a5f4e3d2c1b0a99", 46},
+		{"systemd", "255.4-1ubuntu8.4", "0123456789abcdef0123456789abcdef", 14},
+		{"openssl", "3.0.13-0ubuntu3.4", "b08dfa6083e7567a1921a715000001fb", 39},
+		{"linux-meta", "6.8.0-45.45", "b08dfa6083e7567a1921a715000001fb", 24},
+		{"a", "1", "x", 53},
+		// chars are signed: the UTF-8 bytes are sign-extended
+		{"caf\u00e9", "1.0-1", "4c3a2b1e0f9d8c7b6a5f4e3d2c1b0a99", 56},
+	} {
+		if got := aptPhasedDraw(tc.source + "-" + tc.version + "-" + tc.machineID); got != tc.want {
+			t.Errorf("%s %s %s: draw %d, want %d", tc.source, tc.version, tc.machineID, got, tc.want)
+		}
+	}
+
+	// the ubuntu-24.04 fixture: systemd (10 %) is deferred, tzdata (60 %) not
+	machineID := "4c3a2b1e0f9d8c7b6a5f4e3d2c1b0a99"
+	if !aptPhasedDeferred(aptAvail{Source: "systemd", SourceVersion: "255.4-1ubuntu8.4", Phased: 10}, machineID) {
+		t.Error("systemd not deferred")
+	}
+	if aptPhasedDeferred(aptAvail{Source: "tzdata", SourceVersion: "2024a-3ubuntu1.1", Phased: 60}, machineID) {
+		t.Error("tzdata deferred")
+	}
+	if aptPhasedDeferred(aptAvail{Source: "systemd", SourceVersion: "255.4-1ubuntu8.4", Phased: 10}, "") {
+		t.Error("deferred without machine ID")
+	}
+}
+
+func TestReadDpkgStatus(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "status")
+	if err := os.WriteFile(file, []byte(`Package: libssl3
+Status: install ok installed
+Architecture: amd64
+Source: openssl (3.0.13-1~deb12u1)
+Version: 3.0.13-1~deb12u1+b1
+Description: Secure Sockets Layer toolkit
+ continuation: not a field
+
+Package: linux-image-amd64
+Status: hold ok installed
+Architecture: amd64
+Source: linux-signed-amd64
+Version: 6.1.106-3
+
+Package: exim4-base
+Status: deinstall ok config-files
+Architecture: amd64
+Version: 4.96-15+deb12u5
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := readDpkgStatus(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []debInstalled{
+		{Name: "libssl3", Arch: "amd64", Version: "3.0.13-1~deb12u1+b1", Source: "openssl", SourceVersion: "3.0.13-1~deb12u1"},
+		{Name: "linux-image-amd64", Arch: "amd64", Version: "6.1.106-3", Hold: true, Source: "linux-signed-amd64", SourceVersion: "6.1.106-3"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestAptCandidate(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "preferences")
+	if err := os.WriteFile(file, []byte(`# keep backports below the release
+Package: *
+Pin: release a=bookworm-backports
+Pin-Priority: 100
+
+Package: firefox-esr
+Pin: version 115.*
+Pin-Priority: 1001
+
+Package: /^linux-image-/
+Pin: release o=Debian
+Pin-Priority: -1
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	pins := readAptPreferences([]string{file, filepath.Join(t.TempDir(), "missing")})
+	if len(pins) != 3 {
+		t.Fatalf("pins = %+v", pins)
+	}
+	main := &aptIndex{Release: aptRelease{Origin: "Debian", Suite: "stable"}, Component: "main", Priority: 500}
+	backports := &aptIndex{Release: aptRelease{Origin: "Debian", Suite: "bookworm-backports"}, Component: "main", Priority: 100}
+
+	for _, tc := range []struct {
+		in           debInstalled
+		avail        []aptAvail
+		cand, pinned string
+	}{
+		// same priority: the higher version
+		{debInstalled{Name: "curl", Version: "7.88.1-10+deb12u6"},
+			[]aptAvail{{Version: "7.88.1-10+deb12u7", Index: main}}, "7.88.1-10+deb12u7", ""},
+		// backports stay below the installed version's priority
+		{debInstalled{Name: "curl", Version: "7.88.1-10+deb12u7"},
+			[]aptAvail{{Version: "8.10.1-1~bpo12+1", Index: backports}, {Version: "7.88.1-10+deb12u7", Index: main}}, "7.88.1-10+deb12u7", ""},
+		// a priority of 1000 or more downgrades
+		{debInstalled{Name: "firefox-esr", Version: "128.3.0esr-1~deb12u1"},
+			[]aptAvail{{Version: "115.15.0esr-1~deb12u1", Index: main}}, "115.15.0esr-1~deb12u1", ""},
+		// pinned away: no candidate, the newer version is reported as held
+		{debInstalled{Name: "linux-image-amd64", Version: "6.1.106-3"},
+			[]aptAvail{{Version: "6.1.112-1", Index: main}}, "6.1.106-3", "6.1.112-1"},
+	} {
+		prio, _ := aptPriorities(tc.in, tc.avail, pins)
+		if got := aptCandidate(tc.in, prio); got != tc.cand {
+			t.Errorf("%s %s: candidate %q, want %q", tc.in.Name, tc.in.Version, got, tc.cand)
+		}
+		if got := aptPinnedAway(tc.in, prio); got != tc.pinned {
+			t.Errorf("%s %s: pinned away %q, want %q", tc.in.Name, tc.in.Version, got, tc.pinned)
+		}
+	}
+}
+
+func TestAptDepends(t *testing.T) {
+	got := aptDepends("libc6 (>= 2.34), libssl3:amd64 | libssl1.1, debconf (>= 0.5) | debconf-2.0")
+	want := [][]string{{"libc6"}, {"libssl3", "libssl1.1"}, {"debconf", "debconf-2.0"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("aptDepends = %q, want %q", got, want)
+	}
+	provided := map[string]bool{"libc6": true, "libssl1.1": true, "debconf-2.0": true}
+	if !aptDependsSatisfied("libc6 (>= 2.34), libssl3 | libssl1.1, debconf | debconf-2.0", provided) {
+		t.Error("alternatives not satisfied")
+	}
+	if aptDependsSatisfied("libc6, libzstd1", provided) {
+		t.Error("missing libzstd1 satisfied")
+	}
+	if !aptDependsSatisfied("", provided) {
+		t.Error("no dependencies not satisfied")
+	}
+}
@@ -0,0 +1,124 @@
+package collector
+
+import (
+	"os"
+	"strings"
+)
+
+// Ubuntu phased updates: a candidate with Phased-Update-Percentage < 100 is only
+// installed on the machines whose draw falls within the percentage. apt seeds
+// std::minstd_rand via std::seed_seq with "source-sourceversion-machineid" and
+// draws from std::uniform_int_distribution(0, 100); the functions below
+// reproduce libstdc++ so the exporter defers exactly the updates apt defers.
+// Security updates are never phased.
+//
+// The C++ standard fixes minstd_rand and seed_seq bit for bit; only
+// uniform_int_distribution is up to the library. The draws were checked against
+// the drawing code of apt-pkg/policy.cc (quoted in TestAptPhasedDraw, which pins
+// some of the values) built with GCC 12.2 / libstdc++ 6.0.30, for 3000 random
+// seeds. Ubuntu builds apt with GCC; another libstdc++ version or libc++ may
+// implement the distribution differently. Only APT_NATIVE=1 depends on this: by
+// default phased updates are taken from "apt-get -s dist-upgrade".
+
+// readMachineID returns the machine ID, or "" if it cannot be read.
+func readMachineID(file string) string {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
+
+// aptPhasedDeferred reports whether apt defers the candidate a on this machine.
+// Without a machine ID apt applies no phasing.
+func aptPhasedDeferred(a aptAvail, machineID string) bool {
+	if a.Phased >= 100 || machineID == "" {
+		return false
+	}
+	return aptPhasedDraw(a.Source+"-"+a.SourceVersion+"-"+machineID) > a.Phased
+}
+
+// aptPhasedDraw returns the value in [0, 100] apt draws for seed.
+func aptPhasedDraw(seed string) int {
+	// std::minstd_rand::seed(seed_seq&): k = 1, use the 4th generated word.
+	var words [4]uint32
+	seedSeqGenerate([]byte(seed), words[:])
+	x := uint64(words[3]) % minstdM
+	if x == 0 {
+		x = 1
+	}
+
+	// std::uniform_int_distribution<unsigned>(0, 100), downscaling branch.
+	const urngMin, urngMax = 1, minstdM - 1
+	const urngRange = urngMax - urngMin
+	const uerange = 101
+	const scaling = urngRange / uerange
+	const past = uerange * scaling
+	for {
+		x = x * 48271 % minstdM
+		if ret := x - urngMin; ret < past {
+			return int(ret / scaling)
+		}
+	}
+}
+
+const minstdM = 2147483647
+
+// seedSeqGenerate implements std::seed_seq::generate for the seed bytes
+// (chars are sign-extended like on x86).
+func seedSeqGenerate(seed []byte, out []uint32) {
+	n := len(out)
+	if n == 0 {
+		return
+	}
+	v := make([]uint32, len(seed))
+	for i, c := range seed {
+		v[i] = uint32(int32(int8(c)))
+	}
+	s := len(v)
+	for i := range out {
+		out[i] = 0x8b8b8b8b
+	}
+	var t int
+	switch {
+	case n >= 623:
+		t = 11
+	case n >= 68:
+		t = 7
+	case n >= 39:
+		t = 5
+	case n >= 7:
+		t = 3
+	default:
+		t = (n - 1) / 2
+	}
+	p := (n - t) / 2
+	q := p + t
+	m := s + 1
+	if n > m {
+		m = n
+	}
+	tf := func(x uint32) uint32 { return x ^ (x >> 27) }
+	for k := 0; k < m; k++ {
+		r1 := 1664525 * tf(out[k%n]^out[(k+p)%n]^out[(k+n-1)%n])
+		var r2 uint32
+		switch {
+		case k == 0:
+			r2 = r1 + uint32(s)
+		case k <= s:
+			r2 = r1 + uint32(k%n) + v[k-1]
+		default:
+			r2 = r1 + uint32(k%n)
+		}
+		out[(k+p)%n] += r1
+		out[(k+q)%n] += r2
+		out[k%n] = r2
+	}
+	for k := m; k < m+n; k++ {
+		r3 := 1566083941 * tf(out[k%n]+out[(k+p)%n]+out[(k+n-1)%n])
+		r4 := r3 - uint32(k%n)
+		out[(k+p)%n] ^= r3
+		out[(k+q)%n] ^= r4
+		out[k%n] = r4
+	}
+}
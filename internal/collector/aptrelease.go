@@ -0,0 +1,155 @@
+package collector
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// aptRelease holds the Release/InRelease fields used to classify an apt archive.
+type aptRelease struct {
+	Origin   string
+	Label    string
+	Suite    string
+	Codename string
+
+	NotAutomatic         bool
+	ButAutomaticUpgrades bool
+}
+
+// security follows the unattended-upgrades default origins: Debian archives
+// labelled Debian-Security, and the Ubuntu *-security, UbuntuESMApps
+// *-apps-security and UbuntuESM *-infra-security archives. Third-party
+// archives never count, whatever their label says.
+func (r aptRelease) security() bool {
+	switch r.Origin {
+	case "Debian":
+		return r.Label == "Debian-Security"
+	case "Ubuntu":
+		return strings.HasSuffix(r.archive(), "-security")
+	case "UbuntuESMApps":
+		return strings.HasSuffix(r.archive(), "-apps-security")
+	case "UbuntuESM":
+		return strings.HasSuffix(r.archive(), "-infra-security")
+	}
+	return false
+}
+
+// archive is the name apt shows for the archive: the suite, or the codename
+// if the Release file has no suite. (Ubuntu pockets all share the codename.)
+func (r aptRelease) archive() string {
+	if r.Suite != "" {
+		return r.Suite
+	}
+	return r.Codename
+}
+
+func aptSuiteIsSecurity(suite string) bool {
+	s := strings.ToLower(strings.TrimSpace(suite))
+	return strings.HasSuffix(s, "-security") || strings.HasSuffix(s, "/updates")
+}
+
+// aptArchive identifies a downloaded archive by origin and archive name.
+type aptArchive struct {
+	Origin, Suite string
+}
+
+// aptSecuritySuites maps every downloaded Release file to whether that archive
+// carries security updates.
+func aptSecuritySuites(listsDir string) map[aptArchive]bool {
+	m := map[aptArchive]bool{}
+	files, _ := filepath.Glob(filepath.Join(listsDir, "*Release"))
+	for _, f := range files {
+		r, ok := readAptRelease(f)
+		if !ok {
+			continue
+		}
+		m[aptArchive{Origin: r.Origin, Suite: r.archive()}] = r.security()
+	}
+	return m
+}
+
+// readAptRelease parses the header fields of a Release or InRelease file.
+func readAptRelease(path string) (aptRelease, bool) {
+	fd, err := os.Open(path)
+	if err != nil {
+		return aptRelease{}, false
+	}
+	defer fd.Close()
+
+	r := aptRelease{}
+	sc := bufio.NewScanner(fd)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	pgpHeader := false
+	for sc.Scan() {
+		ln := sc.Text()
+		if strings.HasPrefix(ln, "-----BEGIN PGP SIGNED MESSAGE") {
+			pgpHeader = true
+			continue
+		}
+		if pgpHeader {
+			// armor headers ("Hash: ...") end with an empty line
+			if strings.TrimSpace(ln) == "" {
+				pgpHeader = false
+			}
+			continue
+		}
+		if strings.HasPrefix(ln, "-----BEGIN PGP SIGNATURE") {
+			break
+		}
+		if ln == "" || ln[0] == ' ' || ln[0] == '\t' {
+			continue
+		}
+		key, val, ok := strings.Cut(ln, ":")
+		if !ok {
+			continue
+		}
+		val = strings.TrimSpace(val)
+		switch key {
+		case "Origin":
+			r.Origin = val
+		case "Label":
+			r.Label = val
+		case "Suite":
+			r.Suite = val
+		case "Codename":
+			r.Codename = val
+		case "NotAutomatic":
+			r.NotAutomatic = val == "yes"
+		case "ButAutomaticUpgrades":
+			r.ButAutomaticUpgrades = val == "yes"
+		case "MD5Sum", "SHA1", "SHA256", "SHA512":
+			// checksum lists follow the header fields
+			return r, true
+		}
+	}
+	return r, r.Suite != "" || r.Codename != ""
+}
+
+// aptIsSecurity classifies a candidate by the archives ("suite,suite") it is
+// available from, as printed by apt list. apt list does not print the origin:
+// a suite is security if a security archive of that name was downloaded. The
+// suite name alone decides only for archives without a Release file.
+func aptIsSecurity(suites string, known map[aptArchive]bool) bool {
+	for _, s := range strings.Split(suites, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" || s == "now" {
+			continue
+		}
+		found := false
+		for a, sec := range known {
+			if a.Suite != s {
+				continue
+			}
+			if sec {
+				return true
+			}
+			found = true
+		}
+		if !found && aptSuiteIsSecurity(s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,77 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadAptRelease(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "security.debian.org_debian-security_dists_bookworm-security_InRelease")
+	if err := os.WriteFile(file, []byte(`-----BEGIN PGP SIGNED MESSAGE-----
+Hash: SHA256
+
+Origin: Debian
+Label: Debian-Security
+Suite: stable-security
+Codename: bookworm-security
+Date: Mon, 02 Sep 2024 10:00:00 UTC
+SHA256:
+ 0123456789abcdef 1234 main/binary-amd64/Packages
+Origin: not a header field
+-----BEGIN PGP SIGNATURE-----
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	r, ok := readAptRelease(file)
+	want := aptRelease{Origin: "Debian", Label: "Debian-Security", Suite: "stable-security", Codename: "bookworm-security"}
+	if !ok || r != want {
+		t.Errorf("got %+v %t, want %+v", r, ok, want)
+	}
+}
+
+func TestAptReleaseSecurity(t *testing.T) {
+	for _, tc := range []struct {
+		r    aptRelease
+		want bool
+	}{
+		{aptRelease{Origin: "Debian", Label: "Debian-Security", Suite: "stable-security"}, true},
+		{aptRelease{Origin: "Debian", Label: "Debian", Suite: "stable-updates"}, false},
+		{aptRelease{Origin: "Ubuntu", Label: "Ubuntu", Suite: "noble-security"}, true},
+		{aptRelease{Origin: "Ubuntu", Label: "Ubuntu", Suite: "noble-updates"}, false},
+		{aptRelease{Origin: "UbuntuESMApps", Suite: "noble-apps-security"}, true},
+		{aptRelease{Origin: "UbuntuESM", Suite: "noble-infra-security"}, true},
+		{aptRelease{Origin: "UbuntuESM", Suite: "noble-infra-updates"}, false},
+		// a third-party archive is never security, whatever its name
+		{aptRelease{Origin: "Example", Label: "Debian-Security", Suite: "bookworm-security"}, false},
+	} {
+		if got := tc.r.security(); got != tc.want {
+			t.Errorf("%+v: security = %t, want %t", tc.r, got, tc.want)
+		}
+	}
+}
+
+func TestAptIsSecurity(t *testing.T) {
+	known := map[aptArchive]bool{
+		{Origin: "Ubuntu", Suite: "jammy-security"}:  true,
+		{Origin: "Ubuntu", Suite: "jammy-updates"}:   false,
+		{Origin: "Example", Suite: "tools-security"}: false,
+	}
+	for _, tc := range []struct {
+		suites string
+		want   bool
+	}{
+		{"jammy-updates,jammy-security", true},
+		{"jammy-updates", false},
+		{"now", false},
+		// a downloaded third-party archive decides by its Release file
+		{"tools-security", false},
+		// without a Release file the suite name decides
+		{"bullseye-security", true},
+		{"bullseye/updates", true},
+	} {
+		if got := aptIsSecurity(tc.suites, known); got != tc.want {
+			t.Errorf("aptIsSecurity(%q) = %t, want %t", tc.suites, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package collector
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/R4VXN/os-updates-exporter/internal/config"
+	"github.com/R4VXN/os-updates-exporter/internal/runner"
+)
+
+// Backend is a package manager the collector knows how to query.
+// Backends register themselves from init() via Register.
+type Backend interface {
+	// Name is the value of the manager label.
+	Name() string
+	// Detect reports whether the package manager is present on this host.
+	Detect(env *Env) bool
+	// CollectPending returns the pending updates.
+	CollectPending(ctx context.Context, env *Env) (Pending, error)
+	// ListRepos returns the repository URLs probed by CheckRepos.
+	ListRepos(ctx context.Context, env *Env) []string
+	// MetadataAge returns the age of the oldest repository metadata in seconds.
+	MetadataAge(env *Env) float64
+	// RebootHint is consulted when the generic reboot signals are absent.
+	RebootHint(ctx context.Context, env *Env) (bool, string)
+	// History returns the upgrades recorded by the package manager; ok is false
+	// if it keeps no usable history.
+	History(ctx context.Context, env *Env) (ups []Upgrade, ok bool, err error)
+}
+
+// Env carries the run configuration and the command runner into backend calls.
+type Env struct {
+	Cfg config.Config
+	Run runner.Runner
+	// Root is prepended to the absolute paths read by the collector ("" = /).
+	// It is Cfg.Sysroot in production; tests point it at a fixture.
+	Root string
+}
+
+// Path returns the absolute path p below e.Root.
+func (e *Env) Path(p string) string {
+	if e.Root == "" {
+		return p
+	}
+	return filepath.Join(e.Root, p)
+}
+
+// Live reports whether the running system is inspected. Under a sysroot,
+// checks of running processes, the running kernel and daemons are skipped.
+func (e *Env) Live() bool { return e.Cfg.Sysroot == "" }
+
+// available reports whether the package manager bin can be used. Under a
+// sysroot the binary comes from the exporter's environment, so one of the
+// manager's database paths must also exist below the root.
+func (e *Env) available(bin string, dbs ...string) bool {
+	if !e.Run.Has(bin) {
+		return false
+	}
+	if e.Live() {
+		return true
+	}
+	for _, db := range dbs {
+		if _, err := os.Stat(e.Path(db)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// rpmDBs are the locations of the rpm database (/usr/lib/sysimage/rpm since
+// rpm 4.16 on Fedora and openSUSE).
+var rpmDBs = []string{"/var/lib/rpm", "/usr/lib/sysimage/rpm"}
+
+// Pending is the result of Backend.CollectPending.
+type Pending struct {
+	All      int
+	Security int
+	Bugfix   int
+	// SecurityBySeverity counts security updates per normalized severity. If nil
+	// it is derived from Packages.
+	SecurityBySeverity map[string]int
+	Packages           []Package
+	// CVEs maps the CVE IDs referenced by pending advisories to their severity.
+	CVEs map[string]string
+	// HoldUntil is the end of a manager-wide update hold (unix seconds,
+	// +Inf for forever, 0 for none).
+	HoldUntil float64
+	// Held lists the packages held, pinned away or version-locked by the package
+	// manager, whether or not an update is pending for them. Pending updates
+	// blocked by a hold are included in Packages with Held set.
+	Held []string
+}
+
+var backends []Backend
+
+// Register adds a backend. Collection order follows registration order.
+func Register(b Backend) {
+	backends = append(backends, b)
+}
+
+// Backends returns all registered backends.
+func Backends() []Backend {
+	return append([]Backend(nil), backends...)
+}
+
+// Lookup returns the registered backend with the given name.
+func Lookup(name string) (Backend, bool) {
+	for _, b := range backends {
+		if b.Name() == name {
+			return b, true
+		}
+	}
+	return nil, false
+}
+
+func detectBackends(env *Env) []Backend {
+	found := []Backend{}
+	for _, b := range backends {
+		if b.Detect(env) {
+			found = append(found, b)
+		}
+	}
+	return found
+}
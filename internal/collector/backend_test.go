@@ -0,0 +1,44 @@
+package collector
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/R4VXN/os-updates-exporter/internal/runner/runnertest"
+)
+
+func TestLookup(t *testing.T) {
+	for _, b := range Backends() {
+		if got, ok := Lookup(b.Name()); !ok || got.Name() != b.Name() {
+			t.Errorf("Lookup(%q) = %v, %t", b.Name(), got, ok)
+		}
+	}
+	if _, ok := Lookup("emerge"); ok {
+		t.Error("Lookup of an unregistered backend succeeded")
+	}
+}
+
+func TestDetectBackends(t *testing.T) {
+	for _, tc := range []struct {
+		bins string // recorded commands, see runnertest.Parse
+		want []string
+	}{
+		// yum is an alias of dnf on dnf hosts
+		{bins: "$ dnf\n$ yum\n", want: []string{"dnf"}},
+		{bins: "$ yum\n", want: []string{"yum"}},
+		{bins: "$ apt-get\n$ flatpak\n", want: []string{"apt", "flatpak"}},
+		{bins: "", want: []string{}},
+	} {
+		run, err := runnertest.Parse(tc.bins)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := []string{}
+		for _, b := range detectBackends(&Env{Run: run}) {
+			got = append(got, b.Name())
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%q: detected %v, want %v", tc.bins, got, tc.want)
+		}
+	}
+}
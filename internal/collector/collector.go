@@ -3,81 +3,310 @@ package collector
 import (
 	"context"
 	"errors"
+	"fmt"
 	"os"
 	"runtime"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/R4VXN/os-updates-exporter/internal/config"
 	"github.com/R4VXN/os-updates-exporter/internal/reboot"
+	"github.com/R4VXN/os-updates-exporter/internal/version"
 )
 
+// Result is the host-wide collection result. Pending* are totals across all managers.
 type Result struct {
-	Manager   string
 	OSName    string
 	OSVersion string
+	// OSID and OSCodename are ID and VERSION_CODENAME from os-release.
+	OSID       string
+	OSCodename string
+	// EOL is the end of support of the release, zero if unknown (see eol.txt).
+	EOL time.Time
 
 	PendingSecurity int
 	PendingBugfix   int
 	PendingAll      int
 
-	RiskScore int
+	// PendingHeld and PendingHeldSecurity count the pending updates blocked by
+	// holds or version locks; they are included in the Pending* totals.
+	PendingHeld         int
+	PendingHeldSecurity int
+	// PendingPhased and PendingPhasedSecurity count the updates deferred by
+	// Ubuntu phasing.
+	PendingPhased         int
+	PendingPhasedSecurity int
 
 	RebootRequired bool
 	RebootReason   string
 
+	Managers []ManagerResult
+}
+
+// ManagerResult holds the result of a single package manager.
+type ManagerResult struct {
+	Manager string
+
+	PendingSecurity int
+	PendingBugfix   int
+	PendingAll      int
+
+	// SecurityBySeverity splits PendingSecurity by advisory severity.
+	SecurityBySeverity map[string]int
+	// ByBump counts pending packages per kind of version change (see version.Bumps).
+	ByBump map[string]int
+	// ByState and SecurityByState count pending packages per state (see States),
+	// SecurityByStateSeverity the security updates per state and severity.
+	ByState                 map[string]int
+	SecurityByState         map[string]int
+	SecurityByStateSeverity map[string]map[string]int
+
+	Packages []Package
+
+	// CVEs maps the CVE IDs referenced by pending advisories to their severity.
+	CVEs map[string]string
+
+	// PendingHeld counts pending updates that are held back, PendingHeldSecurity
+	// the security updates among them.
+	PendingHeld         int
+	PendingHeldSecurity int
+	// Held counts the packages held, pinned away or version-locked, whether or
+	// not an update is pending for them.
+	Held int
+	// HoldUntil is the end of a manager-wide update hold, see Pending.HoldUntil.
+	HoldUntil float64
+
 	Repo RepoResult
+
+	// History summarizes the upgrades recorded by the package manager.
+	History HistoryResult
+
+	// Err is the collection error of this manager, if any.
+	Err error
+}
+
+// States are the values of the state label of os_pending_updates:
+// installable by a plain upgrade, kept back because new packages are needed,
+// deferred by phasing, or blocked by a hold.
+var States = []string{"installable", "kept_back", "phased", "held"}
+
+// Package is one pending update as reported by the package manager.
+type Package struct {
+	Name             string
+	Arch             string
+	InstalledVersion string
+	CandidateVersion string
+	Repo             string
+	Class            string // security or bugfix
+	Severity         string // advisory severity of security updates, see Severities
+	Held             bool   // the update is held back by the package manager
+	State            string // installable, kept_back, phased or held, see States
 }
 
 type RepoResult struct {
 	Valid              bool
 	Total              int
-	Unreachable         int
+	Unreachable        int
 	MetadataAgeSeconds float64
 	HeadLatencySeconds float64
 }
 
-func Collect(ctx context.Context, cfg config.Config) (Result, error) {
-	res := Result{Repo: RepoResult{Valid: false}}
-	res.OSName, res.OSVersion = detectOS()
+// Collect queries every detected package manager. The returned error joins the
+// per-manager errors (also kept in ManagerResult.Err), unreadable package
+// manager histories and an unreadable EOL_FILE.
+func Collect(ctx context.Context, env *Env) (Result, error) {
+	res := Result{}
+	rel := detectOS(env.Path("/etc/os-release"))
+	res.OSName, res.OSVersion, res.OSID, res.OSCodename = rel.Name, rel.VersionID, rel.ID, rel.Codename
+	var errs []error
+	eol, err := releaseEOL(rel, env.Cfg.EOLFile)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("eol: %w", err))
+	}
+	res.EOL = eol
 
-	manager := detectManager()
-	if manager == "" {
-		res.Manager = "unknown"
-		return res, errors.New("no supported package manager found")
+	found := detectBackends(env)
+	if len(found) == 0 {
+		err := errors.New("no supported package manager found")
+		res.Managers = []ManagerResult{{Manager: "unknown", Err: err}}
+		return res, errors.Join(append(errs, err)...)
 	}
-	res.Manager = manager
 
-	var err error
-	switch manager {
-	case "apt":
-		res.PendingAll, res.PendingSecurity, res.PendingBugfix, err = collectAPT(ctx)
-	case "dnf":
-		res.PendingAll, res.PendingSecurity, res.PendingBugfix, err = collectDNF(ctx)
-	case "yum":
-		res.PendingAll, res.PendingSecurity, res.PendingBugfix, err = collectYUM(ctx)
-	case "zypper":
-		res.PendingAll, res.PendingSecurity, res.PendingBugfix, err = collectZYPPER(ctx)
+	for _, b := range found {
+		mr := collectBackend(ctx, env, b)
+		if mr.Err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", mr.Manager, mr.Err))
+		}
+		// an unreadable history fails the pkgmgr stage, not the manager
+		if ups, ok, err := b.History(ctx, env); err != nil {
+			errs = append(errs, fmt.Errorf("%s history: %w", mr.Manager, err))
+		} else if ok {
+			mr.History = summarizeHistory(ups, time.Now())
+		}
+		res.add(mr)
 	}
 
-	if res.PendingAll < 0 { res.PendingAll = 0 }
-	if res.PendingSecurity < 0 { res.PendingSecurity = 0 }
-	if res.PendingBugfix < 0 { res.PendingBugfix = 0 }
+	// reboot
+	res.RebootRequired, res.RebootReason = reboot.Detect(env.Root)
+	for _, b := range found {
+		if res.RebootRequired {
+			break
+		}
+		res.RebootRequired, res.RebootReason = b.RebootHint(ctx, env)
+	}
 
-	// risk score: simple weights
-	res.RiskScore = res.PendingBugfix*1 + res.PendingSecurity*5
+	return res, errors.Join(errs...)
+}
 
-	// reboot
-	res.RebootRequired, res.RebootReason = reboot.Detect(manager, ctx)
-	if strings.ToLower(res.RebootReason) == "kernel" && res.PendingSecurity > 0 {
-		res.RiskScore += res.PendingSecurity * 5 // kernel security boost
+// add appends mr to the managers of r and its pending updates to the totals.
+func (r *Result) add(mr ManagerResult) {
+	r.PendingAll += mr.PendingAll
+	r.PendingSecurity += mr.PendingSecurity
+	r.PendingBugfix += mr.PendingBugfix
+	r.PendingHeld += mr.PendingHeld
+	r.PendingHeldSecurity += mr.PendingHeldSecurity
+	r.PendingPhased += mr.ByState["phased"]
+	r.PendingPhasedSecurity += mr.SecurityByState["phased"]
+	r.Managers = append(r.Managers, mr)
+}
+
+// applicationManagers deliver application updates outside the distribution's
+// patch process.
+var applicationManagers = map[string]bool{"snap": true, "flatpak": true}
+
+// OSPackages returns r limited to the OS package managers, leaving out the
+// application updates of snap and flatpak. Patch compliance is computed from
+// it.
+func (r Result) OSPackages() Result {
+	out := r
+	out.Managers = nil
+	out.PendingAll, out.PendingSecurity, out.PendingBugfix = 0, 0, 0
+	out.PendingHeld, out.PendingHeldSecurity = 0, 0
+	out.PendingPhased, out.PendingPhasedSecurity = 0, 0
+	for _, mr := range r.Managers {
+		if !applicationManagers[mr.Manager] {
+			out.add(mr)
+		}
+	}
+	return out
+}
+
+func collectBackend(ctx context.Context, env *Env, b Backend) ManagerResult {
+	mr := ManagerResult{Manager: b.Name()}
+	p, err := b.CollectPending(ctx, env)
+	for i := range p.Packages {
+		switch {
+		case p.Packages[i].Held:
+			p.Packages[i].State = "held"
+		case p.Packages[i].State == "":
+			p.Packages[i].State = "installable"
+		}
 	}
-	return res, err
+	mr.PendingAll, mr.PendingSecurity, mr.PendingBugfix, mr.Packages = p.All, p.Security, p.Bugfix, p.Packages
+	mr.CVEs = p.CVEs
+	mr.HoldUntil = p.HoldUntil
+	mr.Held = len(p.Held)
+	mr.ByBump = map[string]int{}
+	mr.ByState, mr.SecurityByState = map[string]int{}, map[string]int{}
+	mr.SecurityByStateSeverity = map[string]map[string]int{}
+	for _, pkg := range p.Packages {
+		mr.ByState[pkg.State]++
+		if pkg.Class == "security" {
+			mr.SecurityByState[pkg.State]++
+			if mr.SecurityByStateSeverity[pkg.State] == nil {
+				mr.SecurityByStateSeverity[pkg.State] = map[string]int{}
+			}
+			mr.SecurityByStateSeverity[pkg.State][normalizeSeverity(pkg.Severity)]++
+		}
+		if pkg.Held {
+			mr.PendingHeld++
+			if pkg.Class == "security" {
+				mr.PendingHeldSecurity++
+			}
+		}
+		if b := version.Bump(pkg.InstalledVersion, pkg.CandidateVersion); b != "" {
+			mr.ByBump[b]++
+		}
+	}
+	mr.SecurityBySeverity = p.SecurityBySeverity
+	if mr.SecurityBySeverity == nil {
+		mr.SecurityBySeverity = countBySeverity(p.Packages)
+	}
+	mr.Err = err
+
+	if mr.PendingAll < 0 {
+		mr.PendingAll = 0
+	}
+	if mr.PendingSecurity < 0 {
+		mr.PendingSecurity = 0
+	}
+	if mr.PendingBugfix < 0 {
+		mr.PendingBugfix = 0
+	}
+	return mr
 }
 
+// ManagerNames returns the names of the collected managers.
+func (r Result) ManagerNames() []string {
+	names := make([]string, 0, len(r.Managers))
+	for _, m := range r.Managers {
+		names = append(names, m.Manager)
+	}
+	return names
+}
+
+// TopPackages returns up to n pending packages, security updates first,
+// then ordered by name.
+func (r ManagerResult) TopPackages(n int) []Package {
+	if n <= 0 || len(r.Packages) == 0 {
+		return nil
+	}
+	pkgs := append([]Package(nil), r.Packages...)
+	sort.SliceStable(pkgs, func(i, j int) bool {
+		si, sj := pkgs[i].Class == "security", pkgs[j].Class == "security"
+		if si != sj {
+			return si
+		}
+		if pkgs[i].Name != pkgs[j].Name {
+			return pkgs[i].Name < pkgs[j].Name
+		}
+		return pkgs[i].Arch < pkgs[j].Arch
+	})
+	if len(pkgs) > n {
+		pkgs = pkgs[:n]
+	}
+	return pkgs
+}
+
+// Supported reports whether the release is supported at now. Releases without
+// a known end of life count as supported.
+func (r Result) Supported(now time.Time) bool {
+	return r.EOL.IsZero() || now.Before(r.EOL)
+}
+
+// EffectiveCompliant applies the thresholds and the maintenance window margin.
+// Updates blocked by holds or version locks cannot be installed by patching and
+// are left out unless cfg.ComplianceIncludeHeld is set. Phased updates arrive
+// on their own and are always left out. A release past its end of life is
+// never compliant: its repositories no longer publish updates.
 func (r Result) EffectiveCompliant(cfg config.Config) bool {
+	if !r.Supported(time.Now()) {
+		return false
+	}
 	secTh := cfg.PatchThresholdSecurity
 	bugTh := cfg.PatchThresholdBugfix
 
+	r.PendingAll -= r.PendingPhased
+	r.PendingSecurity -= r.PendingPhasedSecurity
+	r.PendingBugfix -= r.PendingPhased - r.PendingPhasedSecurity
+
+	if !cfg.ComplianceIncludeHeld {
+		r.PendingAll -= r.PendingHeld
+		r.PendingSecurity -= r.PendingHeldSecurity
+		r.PendingBugfix -= r.PendingHeld - r.PendingHeldSecurity
+	}
+
 	if secTh <= 0 && bugTh <= 0 {
 		if cfg.InMaintenanceWindow() {
 			return r.PendingAll <= cfg.PatchThreshold*2
@@ -106,36 +335,37 @@ func (r Result) EffectiveCompliant(cfg config.Config) bool {
 	return true
 }
 
-func detectOS() (string, string) {
-	b, err := os.ReadFile("/etc/os-release")
+// osRelease holds the os-release fields the collector uses.
+type osRelease struct {
+	Name, ID, VersionID, Codename string
+	// SupportEnd is SUPPORT_END (zero if unset or invalid).
+	SupportEnd time.Time
+}
+
+func detectOS(file string) osRelease {
+	b, err := os.ReadFile(file)
 	if err != nil {
-		return runtime.GOOS, ""
+		return osRelease{Name: runtime.GOOS}
 	}
-	lines := strings.Split(string(b), "\n")
-	name, ver := "", ""
-	for _, ln := range lines {
-		if strings.HasPrefix(ln, "NAME=") && name == "" {
-			name = strings.Trim(strings.TrimPrefix(ln, "NAME="), `"'`)
+	rel := osRelease{}
+	for _, ln := range strings.Split(string(b), "\n") {
+		key, val, ok := strings.Cut(strings.TrimSpace(ln), "=")
+		if !ok {
+			continue
 		}
-		if strings.HasPrefix(ln, "VERSION_ID=") && ver == "" {
-			ver = strings.Trim(strings.TrimPrefix(ln, "VERSION_ID="), `"'`)
+		val = strings.Trim(val, `"'`)
+		switch key {
+		case "NAME":
+			rel.Name = val
+		case "ID":
+			rel.ID = val
+		case "VERSION_ID":
+			rel.VersionID = val
+		case "VERSION_CODENAME":
+			rel.Codename = val
+		case "SUPPORT_END":
+			rel.SupportEnd, _ = time.Parse("2006-01-02", val)
 		}
 	}
-	return name, ver
-}
-
-func detectManager() string {
-	if hasBin("apt-get") || hasBin("apt") {
-		return "apt"
-	}
-	if hasBin("dnf") {
-		return "dnf"
-	}
-	if hasBin("yum") {
-		return "yum"
-	}
-	if hasBin("zypper") {
-		return "zypper"
-	}
-	return ""
+	return rel
 }
@@ -0,0 +1,100 @@
+package collector
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/R4VXN/os-updates-exporter/internal/config"
+	"github.com/R4VXN/os-updates-exporter/internal/runner/runnertest"
+)
+
+func TestOSPackages(t *testing.T) {
+	res := Result{}
+	res.add(ManagerResult{Manager: "apt", PendingAll: 2, PendingSecurity: 1, PendingBugfix: 1})
+	res.add(ManagerResult{Manager: "snap", PendingAll: 5, PendingBugfix: 5, PendingHeld: 1})
+	res.add(ManagerResult{Manager: "flatpak", PendingAll: 3, PendingBugfix: 3})
+
+	osRes := res.OSPackages()
+	if osRes.PendingAll != 2 || osRes.PendingSecurity != 1 || osRes.PendingBugfix != 1 || osRes.PendingHeld != 0 {
+		t.Errorf("totals = %d/%d/%d held %d, want 2/1/1 held 0", osRes.PendingAll, osRes.PendingSecurity, osRes.PendingBugfix, osRes.PendingHeld)
+	}
+	if len(osRes.Managers) != 1 || len(res.Managers) != 3 {
+		t.Errorf("managers = %v, all = %v", osRes.ManagerNames(), res.ManagerNames())
+	}
+	cfg := config.Config{PatchThreshold: 3}
+	if res.EffectiveCompliant(cfg) || !osRes.EffectiveCompliant(cfg) {
+		t.Error("application updates count against compliance")
+	}
+}
+
+func TestTopPackages(t *testing.T) {
+	mr := ManagerResult{Packages: []Package{
+		{Name: "vim", Arch: "amd64", Class: "bugfix"},
+		{Name: "openssl", Arch: "amd64", Class: "security"},
+		{Name: "bash", Arch: "amd64", Class: "bugfix"},
+		{Name: "libc6", Arch: "i386", Class: "security"},
+		{Name: "libc6", Arch: "amd64", Class: "security"},
+	}}
+	got := []string{}
+	for _, p := range mr.TopPackages(4) {
+		got = append(got, p.Name+":"+p.Arch)
+	}
+	// security updates first, then by name and arch
+	want := []string{"libc6:amd64", "libc6:i386", "openssl:amd64", "bash:amd64"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("TopPackages(4) = %v, want %v", got, want)
+	}
+	if mr.TopPackages(0) != nil {
+		t.Error("TopPackages(0) returned packages")
+	}
+}
+
+func TestCollectAllManagers(t *testing.T) {
+	run, err := runnertest.Parse(`$ apk list -u
+busybox-1.36.1-r29 x86_64 {busybox} (GPL-2.0-only) [upgradable from: busybox-1.36.1-r28]
+libssl3-3.3.2-r0 x86_64 {openssl} (Apache-2.0) [upgradable from: libssl3-3.3.1-r3]
+$ flatpak list --system --columns=application,branch,arch,version
+org.mozilla.firefox	stable	x86_64	129.0
+$ flatpak remote-ls --system --updates --columns=application,branch,arch,version,origin
+? 1
+! error: Unable to load summary from remote flathub
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := &Env{Run: run, Root: filepath.Join("testdata", "alpine-3.20", "root")}
+	res, err := Collect(context.Background(), env)
+	// a failing manager fails the run but does not hide the others
+	if err == nil || !strings.Contains(err.Error(), "flatpak") {
+		t.Errorf("err = %v, want the flatpak error", err)
+	}
+	if got := res.ManagerNames(); !reflect.DeepEqual(got, []string{"apk", "flatpak"}) {
+		t.Fatalf("managers = %v", got)
+	}
+	if res.Managers[0].Err != nil || res.Managers[1].Err == nil {
+		t.Errorf("errors = %v, %v", res.Managers[0].Err, res.Managers[1].Err)
+	}
+	if res.PendingAll != 2 || res.Managers[0].PendingAll != 2 {
+		t.Errorf("pending = %d, apk %d, want 2", res.PendingAll, res.Managers[0].PendingAll)
+	}
+}
+
+func TestCollectBackendByBump(t *testing.T) {
+	run, err := runnertest.Parse(`$ apk list -u
+busybox-1.36.1-r29 x86_64 {busybox} (GPL-2.0-only) [upgradable from: busybox-1.36.1-r28]
+libssl3-3.3.2-r0 x86_64 {openssl} (Apache-2.0) [upgradable from: libssl3-3.3.1-r3]
+libcrypto3-3.3.2-r0 x86_64 {openssl} (Apache-2.0) [upgradable from: libcrypto3-3.3.1-r3]
+python3-3.12.6-r0 x86_64 {python3} (PSF-2.0) [upgradable from: python3-3.11.10-r0]
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mr := collectBackend(context.Background(), &Env{Run: run}, apkBackend{})
+	want := map[string]int{"release": 1, "patch": 2, "minor": 1}
+	if mr.Err != nil || !reflect.DeepEqual(mr.ByBump, want) {
+		t.Errorf("ByBump = %v (err %v), want %v", mr.ByBump, mr.Err, want)
+	}
+}
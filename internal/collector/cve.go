@@ -0,0 +1,173 @@
+package collector
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/R4VXN/os-updates-exporter/internal/runner"
+)
+
+var cveRe = regexp.MustCompile(`CVE-[0-9]{4}-[0-9]{4,}`)
+
+// changelogHeader matches the first line of a Debian changelog entry:
+// "openssl (3.0.13-1~deb12u2) bookworm-security; urgency=medium".
+var changelogHeader = regexp.MustCompile(`^\S+ \(([^)]+)\) `)
+
+// addCVE records id with the more severe of the known severities.
+func addCVE(m map[string]string, id, severity string) {
+	if prev, ok := m[id]; ok {
+		m[id] = maxSeverity(prev, severity)
+		return
+	}
+	m[id] = severity
+}
+
+// parseUpdateinfoCVEs parses "updateinfo list --with-cve" (dnf) and
+// "updateinfo list cves" (yum) rows: "CVE-2023-1234  Important/Sec.  NEVRA".
+func parseUpdateinfoCVEs(out string) map[string]string {
+	m := map[string]string{}
+	for _, ln := range strings.Split(out, "\n") {
+		fields := strings.Fields(ln)
+		if len(fields) < 3 || !cveRe.MatchString(fields[0]) {
+			continue
+		}
+		sev, _, _ := strings.Cut(fields[1], "/")
+		addCVE(m, fields[0], normalizeSeverity(sev))
+	}
+	return m
+}
+
+// parseZypperCVEs parses "zypper lp --cve" rows:
+// "cve | CVE-2023-1234 | SUSE-SLE-...-2023-1 | security | important | ...".
+func parseZypperCVEs(out string) map[string]string {
+	m := map[string]string{}
+	for _, cols := range zypperTable(out) {
+		// Issue | No. | Patch | Category | Severity | Interactive | Status | Summary
+		if len(cols) < 5 || cols[0] != "cve" || !cveRe.MatchString(cols[1]) {
+			continue
+		}
+		addCVE(m, cols[1], normalizeSeverity(cols[4]))
+	}
+	return m
+}
+
+// aptCachedCVEs extracts the CVE IDs mentioned in the changelog entries between
+// the installed and the candidate version, for candidates already downloaded to
+// the apt archive cache (e.g. by unattended-upgrades).
+func aptCachedCVEs(ctx context.Context, r runner.Runner, archives string, pkgs []Package) map[string]string {
+	m := map[string]string{}
+	for _, p := range pkgs {
+		deb := filepath.Join(archives, p.Name+"_"+strings.ReplaceAll(p.CandidateVersion, ":", "%3a")+"_"+p.Arch+".deb")
+		if _, err := os.Stat(deb); err != nil {
+			continue
+		}
+		// the data tarball of a kernel or firmware package is hundreds of
+		// MB: read it as it is unpacked and stop at the changelog
+		var changelog io.Reader
+		res := r.Stream(ctx, func(tarball io.Reader) {
+			changelog = debChangelog(tarball, p.Name)
+		}, "dpkg-deb", "--fsys-tarfile", deb)
+		if res.Check() != nil || changelog == nil {
+			continue
+		}
+		sev := "unknown"
+		if p.Class == "security" && p.Severity != "" {
+			sev = p.Severity
+		}
+		for _, id := range changelogCVEs(changelog, p.InstalledVersion) {
+			addCVE(m, id, sev)
+		}
+	}
+	return m
+}
+
+// debChangelog returns the gunzipped changelog.Debian.gz of pkg from a data
+// tarball. The tarball is read up to the changelog only.
+func debChangelog(tarball io.Reader, pkg string) io.Reader {
+	want := "usr/share/doc/" + pkg + "/changelog.Debian.gz"
+	tr := tar.NewReader(tarball)
+	for {
+		h, err := tr.Next()
+		if err != nil {
+			return nil
+		}
+		if strings.TrimPrefix(h.Name, "./") != want || h.Typeflag != tar.TypeReg {
+			continue
+		}
+		gz, err := gzip.NewReader(tr)
+		if err != nil {
+			return nil
+		}
+		b, err := io.ReadAll(gz)
+		if err != nil {
+			return nil
+		}
+		return bytes.NewReader(b)
+	}
+}
+
+// changelogCVEs collects CVE IDs from Debian changelog entries newer than
+// installed. Entries are newest first; reading stops at the installed version.
+func changelogCVEs(r io.Reader, installed string) []string {
+	seen := map[string]bool{}
+	out := []string{}
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		ln := sc.Text()
+		if m := changelogHeader.FindStringSubmatch(ln); m != nil {
+			if installed != "" && m[1] == installed {
+				break
+			}
+			continue
+		}
+		for _, id := range cveRe.FindAllString(ln, -1) {
+			if !seen[id] {
+				seen[id] = true
+				out = append(out, id)
+			}
+		}
+	}
+	return out
+}
+
+// CVEs merges the pending CVEs of all managers (CVE ID -> highest severity).
+func (r Result) CVEs() map[string]string {
+	m := map[string]string{}
+	for _, mr := range r.Managers {
+		for id, sev := range mr.CVEs {
+			addCVE(m, id, sev)
+		}
+	}
+	return m
+}
+
+// TopCVEs orders CVE IDs by severity, then by ID, and returns at most n.
+func TopCVEs(cves map[string]string, n int) []string {
+	if n <= 0 {
+		return nil
+	}
+	ids := make([]string, 0, len(cves))
+	for id := range cves {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		ri, rj := severityRank(cves[ids[i]]), severityRank(cves[ids[j]])
+		if ri != rj {
+			return ri > rj
+		}
+		return ids[i] < ids[j]
+	})
+	if len(ids) > n {
+		ids = ids[:n]
+	}
+	return ids
+}
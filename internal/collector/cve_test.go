@@ -0,0 +1,102 @@
+package collector
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestDebChangelog(t *testing.T) {
+	changelog := `openssl (3.0.14-1~deb12u2) bookworm-security; urgency=medium
+
+  * Fix CVE-2024-5535 and CVE-2024-6119.
+
+ -- Debian Security Team <team@security.debian.org>  Mon, 02 Sep 2024 10:00:00 +0000
+
+openssl (3.0.14-1~deb12u1) bookworm; urgency=medium
+
+  * Fix CVE-2024-4741.
+
+ -- Debian Security Team <team@security.debian.org>  Mon, 01 Jul 2024 10:00:00 +0000
+
+openssl (3.0.13-1~deb12u1) bookworm; urgency=medium
+
+  * Fix CVE-2024-0727.
+`
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	_, _ = zw.Write([]byte(changelog))
+	_ = zw.Close()
+
+	var data bytes.Buffer
+	tw := tar.NewWriter(&data)
+	for _, f := range []struct {
+		name string
+		body []byte
+	}{
+		{"./usr/bin/openssl", []byte("\x7fELF")},
+		{"./usr/share/doc/openssl/changelog.Debian.gz", gz.Bytes()},
+	} {
+		_ = tw.WriteHeader(&tar.Header{Name: f.name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(f.body))})
+		_, _ = tw.Write(f.body)
+	}
+	_ = tw.Flush()
+	// anything after the changelog must not be read
+	tarball := io.MultiReader(&data, failingReader{t})
+
+	r := debChangelog(tarball, "openssl")
+	if r == nil {
+		t.Fatal("changelog not found")
+	}
+	got := changelogCVEs(r, "3.0.14-1~deb12u1")
+	if want := []string{"CVE-2024-5535", "CVE-2024-6119"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("cves = %v, want %v", got, want)
+	}
+}
+
+type failingReader struct{ t *testing.T }
+
+func (f failingReader) Read([]byte) (int, error) {
+	f.t.Error("tarball read past the changelog")
+	return 0, io.ErrUnexpectedEOF
+}
+
+func TestParseUpdateinfoCVEs(t *testing.T) {
+	out := `CVE-2024-5535  Critical/Sec.  openssl-libs-1:3.0.7-28.el9_4.x86_64
+CVE-2024-5535  Moderate/Sec.  openssl-1:3.0.7-28.el9_4.x86_64
+CVE-2024-6119  Low/Sec.       openssl-1:3.0.7-28.el9_4.x86_64
+RHSA-2024:5101 Important/Sec. kernel-5.14.0-427.31.1.el9_4.x86_64
+`
+	want := map[string]string{"CVE-2024-5535": "critical", "CVE-2024-6119": "low"}
+	if got := parseUpdateinfoCVEs(out); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestParseZypperCVEs(t *testing.T) {
+	out := `Issue | No.           | Patch                       | Category | Severity  | Interactive | Status | Summary
+------+---------------+-----------------------------+----------+-----------+-------------+--------+--------
+cve   | CVE-2024-8381 | openSUSE-SLE-15.6-2024-3201 | security | important | ---         | needed | Security update for MozillaFirefox
+bugzilla | 1229821    | openSUSE-SLE-15.6-2024-3201 | security | important | ---         | needed | Security update for MozillaFirefox
+`
+	want := map[string]string{"CVE-2024-8381": "important"}
+	if got := parseZypperCVEs(out); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestTopCVEs(t *testing.T) {
+	cves := map[string]string{
+		"CVE-2024-0003": "low",
+		"CVE-2024-0002": "critical",
+		"CVE-2024-0001": "moderate",
+		"CVE-2023-9999": "critical",
+	}
+	want := []string{"CVE-2023-9999", "CVE-2024-0002", "CVE-2024-0001"}
+	if got := TopCVEs(cves, 3); !reflect.DeepEqual(got, want) {
+		t.Errorf("TopCVEs = %v, want %v", got, want)
+	}
+}
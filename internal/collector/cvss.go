@@ -0,0 +1,89 @@
+package collector
+
+import (
+	"math"
+	"strings"
+)
+
+// cvss3Weights are the CVSS v3.x base metric weights. PR weighs more when the
+// scope changes, see cvss3Score.
+var cvss3Weights = map[string]map[string]float64{
+	"AV": {"N": 0.85, "A": 0.62, "L": 0.55, "P": 0.2},
+	"AC": {"L": 0.77, "H": 0.44},
+	"PR": {"N": 0.85, "L": 0.62, "H": 0.27},
+	"UI": {"N": 0.85, "R": 0.62},
+	"C":  {"H": 0.56, "L": 0.22, "N": 0},
+	"I":  {"H": 0.56, "L": 0.22, "N": 0},
+	"A":  {"H": 0.56, "L": 0.22, "N": 0},
+}
+
+// cvss3Score computes the base score of a CVSS v3.0/v3.1 vector such as
+// "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H".
+func cvss3Score(vector string) (float64, bool) {
+	parts := strings.Split(vector, "/")
+	if len(parts) == 0 || !strings.HasPrefix(parts[0], "CVSS:3") {
+		return 0, false
+	}
+	m := map[string]string{}
+	for _, p := range parts[1:] {
+		if k, v, ok := strings.Cut(p, ":"); ok {
+			m[k] = v
+		}
+	}
+	changed := m["S"] == "C"
+	w := map[string]float64{}
+	for k, vals := range cvss3Weights {
+		v, ok := vals[m[k]]
+		if !ok {
+			return 0, false
+		}
+		w[k] = v
+	}
+	if changed {
+		switch m["PR"] {
+		case "L":
+			w["PR"] = 0.68
+		case "H":
+			w["PR"] = 0.5
+		}
+	}
+
+	iss := 1 - (1-w["C"])*(1-w["I"])*(1-w["A"])
+	impact := 6.42 * iss
+	if changed {
+		impact = 7.52*(iss-0.029) - 3.25*math.Pow(iss-0.02, 15)
+	}
+	if impact <= 0 {
+		return 0, true
+	}
+	exploit := 8.22 * w["AV"] * w["AC"] * w["PR"] * w["UI"]
+	if changed {
+		return cvssRoundup(math.Min(1.08*(impact+exploit), 10)), true
+	}
+	return cvssRoundup(math.Min(impact+exploit, 10)), true
+}
+
+// cvssRoundup rounds up to one decimal as specified by CVSS v3.1.
+func cvssRoundup(x float64) float64 {
+	i := math.Round(x * 100000)
+	if math.Mod(i, 10000) == 0 {
+		return i / 100000
+	}
+	return (math.Floor(i/10000) + 1) / 10
+}
+
+// cvssSeverity maps a CVSS score onto Severities.
+func cvssSeverity(score float64) string {
+	switch {
+	case score >= 9:
+		return "critical"
+	case score >= 7:
+		return "important"
+	case score >= 4:
+		return "moderate"
+	case score > 0:
+		return "low"
+	default:
+		return "unknown"
+	}
+}
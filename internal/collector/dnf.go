@@ -2,28 +2,58 @@ package collector
 
 import (
 	"context"
-	"strings"
+
+	"github.com/R4VXN/os-updates-exporter/internal/reboot"
+	"github.com/R4VXN/os-updates-exporter/internal/runner"
 )
 
-// Best-effort: dnf check-update. Security split: dnf updateinfo list security count (if available).
-func collectDNF(ctx context.Context) (all, sec, bug int, err error) {
-	out, err := runCmd(ctx, "bash", "-lc", `LANG=C dnf -q check-update || true`)
-	for _, ln := range strings.Split(out, "\n") {
-		ln = strings.TrimSpace(ln)
-		if ln == "" || strings.HasPrefix(ln, "Last metadata") {
-			continue
-		}
-		if strings.Count(ln, " ") < 2 {
-			continue
-		}
-		all++
+type dnfBackend struct{}
+
+func init() { Register(dnfBackend{}) }
+
+func (dnfBackend) Name() string { return "dnf" }
+
+func (dnfBackend) Detect(env *Env) bool { return env.available("dnf", rpmDBs...) }
+
+func (dnfBackend) CollectPending(ctx context.Context, env *Env) (Pending, error) {
+	return collectDNF(ctx, env.Run)
+}
+
+func (dnfBackend) ListRepos(ctx context.Context, env *Env) []string { return parseYumRepos(env) }
+
+func (dnfBackend) MetadataAge(env *Env) float64 { return rpmMetadataAge(env) }
+
+func (dnfBackend) RebootHint(ctx context.Context, env *Env) (bool, string) {
+	if !env.Live() {
+		return false, "unknown"
 	}
+	return reboot.NeedsRestarting(ctx, env.Run)
+}
+
+func (dnfBackend) History(ctx context.Context, env *Env) ([]Upgrade, bool, error) {
+	ups, err := rpmHistory(ctx, env.Run, "dnf", "-q", "history", "list")
+	return ups, true, err
+}
 
-	secOut, _ := runCmd(ctx, "bash", "-lc", `LANG=C dnf -q updateinfo list security 2>/dev/null | wc -l || true`)
-	sec = atoiSafe(strings.TrimSpace(secOut))
-	if sec > all {
-		sec = all
+// Best-effort: dnf check-update. Security split: packages referenced by dnf updateinfo list security.
+// Updates blocked by the versionlock plugin are found by repeating check-update with the plugin disabled.
+func collectDNF(ctx context.Context, r runner.Runner) (Pending, error) {
+	p := Pending{}
+	// check-update exits 100 if updates are available
+	res := r.Run(ctx, "dnf", "-q", "check-update")
+	installed := rpmInstalled(ctx, r)
+	pkgs := parseCheckUpdate(res.Stdout, installed)
+
+	p.Held = parseVersionlock(r.Run(ctx, "dnf", "-q", "versionlock", "list").Stdout)
+	if len(p.Held) > 0 {
+		allOut := r.Run(ctx, "dnf", "-q", "--disableplugin=versionlock", "check-update").Stdout
+		pkgs = append(pkgs, rpmLockedUpdates(parseCheckUpdate(allOut, installed), pkgs, p.Held)...)
 	}
-	bug = all - sec
-	return all, sec, bug, err
+
+	secOut := r.Run(ctx, "dnf", "-q", "updateinfo", "list", "security").Stdout
+	p.All, p.Security, p.Bugfix = classifyRPM(pkgs, parseUpdateinfoNames(secOut))
+	p.Packages = pkgs
+
+	p.CVEs = parseUpdateinfoCVEs(r.Run(ctx, "dnf", "-q", "updateinfo", "list", "--with-cve").Stdout)
+	return p, res.Check(0, 100)
 }
@@ -0,0 +1,92 @@
+package collector
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+//go:embed eol.txt
+var embeddedEOL string
+
+// eolEntry is one row of an EOL table: the os-release ID, a VERSION_ID (prefix)
+// or VERSION_CODENAME and the first day without support.
+type eolEntry struct {
+	ID, Version string
+	End         time.Time
+}
+
+// releaseEOL returns the end of support of rel (zero if unknown). Entries of
+// the override file take precedence over SUPPORT_END, which takes precedence
+// over the embedded table.
+func releaseEOL(rel osRelease, override string) (time.Time, error) {
+	if override != "" {
+		b, err := os.ReadFile(override)
+		if err != nil {
+			return time.Time{}, err
+		}
+		table, err := parseEOLTable(string(b))
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%s: %w", override, err)
+		}
+		if t := lookupEOL(table, rel); !t.IsZero() {
+			return t, nil
+		}
+	}
+	if !rel.SupportEnd.IsZero() {
+		return rel.SupportEnd, nil
+	}
+	table, err := parseEOLTable(embeddedEOL)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return lookupEOL(table, rel), nil
+}
+
+// parseEOLTable parses "ID VERSION YYYY-MM-DD" rows; "#" starts a comment.
+func parseEOLTable(s string) ([]eolEntry, error) {
+	out := []eolEntry{}
+	for i, ln := range strings.Split(s, "\n") {
+		ln, _, _ = strings.Cut(ln, "#")
+		fields := strings.Fields(ln)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("line %d: want ID VERSION DATE", i+1)
+		}
+		end, err := time.Parse("2006-01-02", fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		out = append(out, eolEntry{ID: fields[0], Version: fields[1], End: end})
+	}
+	return out, nil
+}
+
+// lookupEOL finds the entry for rel: the full VERSION_ID first, then shorter
+// prefixes ("9.4", "9"), then the codename.
+func lookupEOL(table []eolEntry, rel osRelease) time.Time {
+	keys := []string{}
+	for v := rel.VersionID; v != ""; {
+		keys = append(keys, v)
+		i := strings.LastIndex(v, ".")
+		if i < 0 {
+			break
+		}
+		v = v[:i]
+	}
+	if rel.Codename != "" {
+		keys = append(keys, rel.Codename)
+	}
+	for _, k := range keys {
+		for _, e := range table {
+			if e.ID == rel.ID && e.Version == k {
+				return e.End
+			}
+		}
+	}
+	return time.Time{}
+}
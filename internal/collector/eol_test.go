@@ -0,0 +1,50 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReleaseEOL(t *testing.T) {
+	override := filepath.Join(t.TempDir(), "eol.txt")
+	if err := os.WriteFile(override, []byte("# local policy\nrhel 9.4 2025-05-01\ndebian trixie 2030-07-01\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fedoraEnd, _ := time.Parse("2006-01-02", "2025-05-13")
+	for _, tc := range []struct {
+		rel      osRelease
+		override string
+		want     string
+	}{
+		{rel: osRelease{ID: "rhel", VersionID: "9.4"}, want: "2032-06-01"},
+		{rel: osRelease{ID: "rhel", VersionID: "9.4"}, override: override, want: "2025-05-01"},
+		{rel: osRelease{ID: "rhel", VersionID: "8.10"}, override: override, want: "2029-06-01"},
+		{rel: osRelease{ID: "alpine", VersionID: "3.20.3"}, want: "2026-04-01"},
+		{rel: osRelease{ID: "debian", Codename: "trixie"}, override: override, want: "2030-07-01"},
+		{rel: osRelease{ID: "fedora", VersionID: "40", SupportEnd: fedoraEnd}, want: "2025-05-13"},
+		{rel: osRelease{ID: "debian", Codename: "sid"}, want: ""},
+	} {
+		got, err := releaseEOL(tc.rel, tc.override)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := ""
+		if !got.IsZero() {
+			s = got.Format("2006-01-02")
+		}
+		if s != tc.want {
+			t.Errorf("releaseEOL(%+v) = %q, want %q", tc.rel, s, tc.want)
+		}
+	}
+}
+
+func TestEOLTableValid(t *testing.T) {
+	if _, err := parseEOLTable(embeddedEOL); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parseEOLTable("debian 12\n"); err == nil {
+		t.Error("short row accepted")
+	}
+}
@@ -0,0 +1,290 @@
+package collector
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/R4VXN/os-updates-exporter/internal/config"
+	"github.com/R4VXN/os-updates-exporter/internal/runner/runnertest"
+)
+
+// The fixtures in testdata/<distro> hold the recorded output of every command
+// the collector runs (commands.txt, see runnertest.Parse) and the host files it
+// reads (root/).
+var fixtures = []struct {
+	dir string
+	cfg config.Config
+
+	osName, osVersion string
+	reboot            bool
+	rebootReason      string
+
+	manager               string
+	all, security, bugfix int
+	pendingHeld, held     int
+	bySeverity            map[string]int
+	byState               map[string]int
+	securityByState       map[string]int
+	cves                  int
+	// err is set if the fixture cannot be fully classified
+	err bool
+}{
+	{
+		dir:    "debian-11",
+		osName: "Debian GNU/Linux", osVersion: "11", rebootReason: "unknown",
+		manager: "apt", all: 6, security: 3, bugfix: 3, pendingHeld: 1, held: 1,
+		bySeverity:      map[string]int{"unknown": 3},
+		byState:         map[string]int{"installable": 5, "held": 1},
+		securityByState: map[string]int{"installable": 2, "held": 1},
+	},
+	{
+		// a third-party archive labelled "Example Security Tools" also
+		// publishes a "stable" suite: the stable updates stay bugfix.
+		// postgresql 15.8 is pinned away, apt list does not show it
+		dir:    "debian-12",
+		osName: "Debian GNU/Linux", osVersion: "12", reboot: true, rebootReason: "libc",
+		manager: "apt", all: 8, security: 2, bugfix: 6, pendingHeld: 2, held: 2,
+		bySeverity:      map[string]int{"unknown": 2},
+		byState:         map[string]int{"installable": 5, "kept_back": 1, "held": 2},
+		securityByState: map[string]int{"installable": 2},
+	},
+	{
+		dir:    "ubuntu-22.04",
+		osName: "Ubuntu", osVersion: "22.04", reboot: true, rebootReason: "kernel",
+		manager: "apt", all: 11, security: 7, bugfix: 4,
+		bySeverity:      map[string]int{"unknown": 7},
+		byState:         map[string]int{"installable": 5, "kept_back": 3, "phased": 3},
+		securityByState: map[string]int{"installable": 4, "kept_back": 3},
+	},
+	{
+		dir:    "ubuntu-24.04",
+		cfg:    config.Config{AptNative: true},
+		osName: "Ubuntu", osVersion: "24.04", rebootReason: "unknown",
+		manager: "apt", all: 8, security: 4, bugfix: 4, pendingHeld: 2, held: 2,
+		bySeverity:      map[string]int{"unknown": 4},
+		byState:         map[string]int{"installable": 3, "kept_back": 1, "phased": 2, "held": 2},
+		securityByState: map[string]int{"installable": 2, "kept_back": 1, "held": 1},
+	},
+	{
+		dir:    "rhel-8",
+		osName: "Red Hat Enterprise Linux", osVersion: "8.10", rebootReason: "unknown",
+		manager: "dnf", all: 4, security: 3, bugfix: 1,
+		bySeverity:      map[string]int{"critical": 2, "moderate": 1},
+		byState:         map[string]int{"installable": 4},
+		securityByState: map[string]int{"installable": 3},
+		cves:            2,
+	},
+	{
+		dir:    "rhel-9",
+		osName: "Red Hat Enterprise Linux", osVersion: "9.4", reboot: true, rebootReason: "kernel",
+		manager: "dnf", all: 7, security: 5, bugfix: 2, pendingHeld: 1, held: 1,
+		bySeverity:      map[string]int{"important": 2, "moderate": 2, "low": 1},
+		byState:         map[string]int{"installable": 6, "held": 1},
+		securityByState: map[string]int{"installable": 5},
+		cves:            4,
+	},
+	{
+		dir:    "fedora-40",
+		osName: "Fedora Linux", osVersion: "40", rebootReason: "unknown",
+		manager: "dnf", all: 6, security: 3, bugfix: 3,
+		bySeverity:      map[string]int{"moderate": 2, "unknown": 1},
+		byState:         map[string]int{"installable": 6},
+		securityByState: map[string]int{"installable": 3},
+		cves:            2,
+	},
+	{
+		dir:    "sles-15",
+		osName: "SLES", osVersion: "15.6", reboot: true, rebootReason: "systemd",
+		manager: "zypper", all: 5, security: 3, bugfix: 2, pendingHeld: 1, held: 1,
+		bySeverity:      map[string]int{"important": 1, "moderate": 2},
+		byState:         map[string]int{"installable": 4, "held": 1},
+		securityByState: map[string]int{"installable": 2, "held": 1},
+		cves:            3,
+	},
+	{
+		// the security patch lists no conflicts: the security split is
+		// unknown and the manager fails
+		dir:    "opensuse-leap-15.6",
+		osName: "openSUSE Leap", osVersion: "15.6", rebootReason: "unknown",
+		manager: "zypper", all: 4, security: 0, bugfix: 4, pendingHeld: 2, held: 1,
+		bySeverity:      map[string]int{},
+		byState:         map[string]int{"installable": 2, "held": 2},
+		securityByState: map[string]int{},
+		cves:            2,
+		err:             true,
+	},
+	{
+		dir:    "alpine-3.20",
+		osName: "Alpine Linux", osVersion: "3.20.3", rebootReason: "unknown",
+		manager: "apk", all: 4, bugfix: 4,
+		bySeverity:      map[string]int{},
+		byState:         map[string]int{"installable": 4},
+		securityByState: map[string]int{},
+	},
+}
+
+func TestCollectFixtures(t *testing.T) {
+	for _, fx := range fixtures {
+		t.Run(fx.dir, func(t *testing.T) {
+			run, err := runnertest.Load(filepath.Join("testdata", fx.dir, "commands.txt"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			env := &Env{Cfg: fx.cfg, Run: run, Root: filepath.Join("testdata", fx.dir, "root")}
+			res, err := Collect(context.Background(), env)
+			if (err != nil) != fx.err {
+				t.Fatalf("Collect: %v", err)
+			}
+
+			if res.OSName != fx.osName || res.OSVersion != fx.osVersion {
+				t.Errorf("os = %q %q, want %q %q", res.OSName, res.OSVersion, fx.osName, fx.osVersion)
+			}
+			if res.RebootRequired != fx.reboot || res.RebootReason != fx.rebootReason {
+				t.Errorf("reboot = %t %q, want %t %q", res.RebootRequired, res.RebootReason, fx.reboot, fx.rebootReason)
+			}
+			if len(res.Managers) != 1 {
+				t.Fatalf("managers = %v, want [%s]", res.ManagerNames(), fx.manager)
+			}
+			mr := res.Managers[0]
+			if mr.Manager != fx.manager {
+				t.Errorf("manager = %q, want %q", mr.Manager, fx.manager)
+			}
+			if mr.PendingAll != fx.all || mr.PendingSecurity != fx.security || mr.PendingBugfix != fx.bugfix {
+				t.Errorf("pending all/security/bugfix = %d/%d/%d, want %d/%d/%d",
+					mr.PendingAll, mr.PendingSecurity, mr.PendingBugfix, fx.all, fx.security, fx.bugfix)
+			}
+			if len(mr.Packages) != fx.all {
+				t.Errorf("packages = %d, want %d", len(mr.Packages), fx.all)
+			}
+			if mr.PendingHeld != fx.pendingHeld || mr.Held != fx.held {
+				t.Errorf("pending held/held = %d/%d, want %d/%d", mr.PendingHeld, mr.Held, fx.pendingHeld, fx.held)
+			}
+			if !reflect.DeepEqual(mr.SecurityBySeverity, fx.bySeverity) {
+				t.Errorf("security by severity = %v, want %v", mr.SecurityBySeverity, fx.bySeverity)
+			}
+			if !reflect.DeepEqual(mr.ByState, fx.byState) {
+				t.Errorf("by state = %v, want %v", mr.ByState, fx.byState)
+			}
+			if !reflect.DeepEqual(mr.SecurityByState, fx.securityByState) {
+				t.Errorf("security by state = %v, want %v", mr.SecurityByState, fx.securityByState)
+			}
+			bySeverity := map[string]int{}
+			for _, m := range mr.SecurityByStateSeverity {
+				for sev, n := range m {
+					bySeverity[sev] += n
+				}
+			}
+			if !reflect.DeepEqual(bySeverity, fx.bySeverity) {
+				t.Errorf("security by state and severity = %v, want %v by severity", mr.SecurityByStateSeverity, fx.bySeverity)
+			}
+			if len(mr.CVEs) != fx.cves {
+				t.Errorf("cves = %v, want %d", mr.CVEs, fx.cves)
+			}
+			for _, p := range mr.Packages {
+				if p.Name == "" || p.InstalledVersion == "" || p.CandidateVersion == "" {
+					t.Errorf("incomplete package %+v", p)
+				}
+			}
+		})
+	}
+}
+
+func TestCollectSysroot(t *testing.T) {
+	for _, tc := range []struct {
+		dir      string
+		native   bool
+		managers int
+	}{
+		// native apt needs no apt binary, only the dpkg status below the root
+		{dir: "ubuntu-24.04", native: true, managers: 1},
+		// dnf is found but there is no rpm database below the root
+		{dir: "rhel-9", managers: 0},
+	} {
+		t.Run(tc.dir, func(t *testing.T) {
+			run, err := runnertest.Load(filepath.Join("testdata", tc.dir, "commands.txt"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			root := filepath.Join("testdata", tc.dir, "root")
+			env := &Env{Cfg: config.Config{AptNative: tc.native, Sysroot: root}, Run: run, Root: root}
+			res, _ := Collect(context.Background(), env)
+			n := 0
+			for _, mr := range res.Managers {
+				if mr.Manager != "unknown" {
+					n++
+				}
+			}
+			if n != tc.managers {
+				t.Errorf("managers = %v, want %d", res.ManagerNames(), tc.managers)
+			}
+			for _, r := range run.Runs() {
+				if r.Command == "needs-restarting -r" {
+					t.Errorf("ran %q under a sysroot", r.Command)
+				}
+			}
+		})
+	}
+}
+
+func TestCollectHistory(t *testing.T) {
+	for _, tc := range []struct {
+		dir            string
+		cfg            config.Config
+		last, security string // local time, "" = none
+	}{
+		// the libc6 update is published in noble-security
+		{dir: "ubuntu-24.04", cfg: config.Config{AptNative: true}, last: "2024-08-14 06:12:40", security: "2024-08-14 06:12:40"},
+		{dir: "rhel-9", last: "2024-08-02 04:10:00", security: "2024-08-02 04:10:00"},
+		{dir: "rhel-8", last: "2024-06-11 03:00:00"},
+		{dir: "fedora-40", last: "2024-09-10 07:30:00", security: "2024-09-10 07:30:00"},
+		{dir: "sles-15", last: "2024-08-19 10:15:45", security: "2024-07-04 02:00:04"},
+		// no /var/log/apt/history.log
+		{dir: "debian-12"},
+	} {
+		t.Run(tc.dir, func(t *testing.T) {
+			run, err := runnertest.Load(filepath.Join("testdata", tc.dir, "commands.txt"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			env := &Env{Cfg: tc.cfg, Run: run, Root: filepath.Join("testdata", tc.dir, "root")}
+			res, err := Collect(context.Background(), env)
+			if err != nil {
+				t.Fatalf("Collect: %v", err)
+			}
+			h := res.Managers[0].History
+			if !h.Valid {
+				t.Fatal("no history")
+			}
+			format := func(ts time.Time) string {
+				if ts.IsZero() {
+					return ""
+				}
+				return ts.In(time.Local).Format("2006-01-02 15:04:05")
+			}
+			if got := format(h.LastUpgrade); got != tc.last {
+				t.Errorf("last upgrade = %q, want %q", got, tc.last)
+			}
+			if got := format(h.LastSecurityUpgrade); got != tc.security {
+				t.Errorf("last security upgrade = %q, want %q", got, tc.security)
+			}
+		})
+	}
+}
+
+func TestSummarizeHistory(t *testing.T) {
+	now := time.Now()
+	h := summarizeHistory([]Upgrade{
+		{Time: now.Add(-2 * time.Hour), Packages: 3},
+		{Time: now.Add(-3 * 24 * time.Hour), Packages: 5, Security: true},
+		{Time: now.Add(-30 * 24 * time.Hour), Packages: 40},
+	}, now)
+	if h.Upgraded24h != 3 || h.Upgraded7d != 8 {
+		t.Errorf("upgraded 24h/7d = %d/%d, want 3/8", h.Upgraded24h, h.Upgraded7d)
+	}
+	if !h.LastUpgrade.Equal(now.Add(-2*time.Hour)) || !h.LastSecurityUpgrade.Equal(now.Add(-3*24*time.Hour)) {
+		t.Errorf("last = %v, last security = %v", h.LastUpgrade, h.LastSecurityUpgrade)
+	}
+}
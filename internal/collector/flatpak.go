@@ -0,0 +1,94 @@
+package collector
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/R4VXN/os-updates-exporter/internal/runner"
+)
+
+type flatpakBackend struct{}
+
+func init() { Register(flatpakBackend{}) }
+
+func (flatpakBackend) Name() string { return "flatpak" }
+
+// Detect: flatpak is only collected on the running system (no root option, see runner.RootArgs).
+func (flatpakBackend) Detect(env *Env) bool { return env.Live() && env.Run.Has("flatpak") }
+
+func (flatpakBackend) CollectPending(ctx context.Context, env *Env) (Pending, error) {
+	all, pkgs, err := collectFLATPAK(ctx, env.Run)
+	return Pending{All: all, Bugfix: all, Packages: pkgs}, err
+}
+
+// ListRepos returns the config file URL of every http(s) remote.
+func (flatpakBackend) ListRepos(ctx context.Context, env *Env) []string {
+	out := env.Run.Run(ctx, "flatpak", "remotes", "--system", "--columns=name,url").Stdout
+	urls := []string{}
+	for _, ln := range strings.Split(out, "\n") {
+		fields := strings.Fields(ln)
+		if len(fields) < 2 || !strings.HasPrefix(fields[1], "http") {
+			continue
+		}
+		urls = append(urls, strings.TrimSuffix(fields[1], "/")+"/config")
+	}
+	return urls
+}
+
+func (flatpakBackend) MetadataAge(env *Env) float64 {
+	return maxFileAge(env.Path("/var/lib/flatpak/appstream"), ".timestamp", time.Now())
+}
+
+func (flatpakBackend) RebootHint(ctx context.Context, env *Env) (bool, string) {
+	return false, "unknown"
+}
+
+func (flatpakBackend) History(ctx context.Context, env *Env) ([]Upgrade, bool, error) {
+	return nil, false, nil
+}
+
+// Best-effort: flatpak remote-ls --updates for the system installation.
+func collectFLATPAK(ctx context.Context, r runner.Runner) (all int, pkgs []Package, err error) {
+	listOut := r.Run(ctx, "flatpak", "list", "--system", "--columns=application,branch,arch,version").Stdout
+	installed := map[string]string{}
+	for _, cols := range flatpakRows(listOut, 4) {
+		installed[cols[0]+"/"+cols[2]+"/"+cols[1]] = cols[3]
+	}
+
+	res := r.Run(ctx, "flatpak", "remote-ls", "--system", "--updates", "--columns=application,branch,arch,version,origin")
+	for _, cols := range flatpakRows(res.Stdout, 5) {
+		pkgs = append(pkgs, Package{
+			Name:             cols[0] + "//" + cols[1],
+			Arch:             cols[2],
+			InstalledVersion: installed[cols[0]+"/"+cols[2]+"/"+cols[1]],
+			CandidateVersion: cols[3],
+			Repo:             cols[4],
+			Class:            "bugfix",
+		})
+	}
+	return len(pkgs), pkgs, res.Check()
+}
+
+// flatpakRows splits tab separated --columns output, skipping a header row.
+// Rows are padded to n columns (the version column is often empty).
+func flatpakRows(out string, n int) [][]string {
+	rows := [][]string{}
+	for _, ln := range strings.Split(out, "\n") {
+		if strings.TrimSpace(ln) == "" {
+			continue
+		}
+		cols := strings.Split(ln, "\t")
+		if strings.HasPrefix(cols[0], "Application") {
+			continue
+		}
+		for len(cols) < n {
+			cols = append(cols, "")
+		}
+		for i := range cols {
+			cols[i] = strings.TrimSpace(cols[i])
+		}
+		rows = append(rows, cols)
+	}
+	return rows
+}
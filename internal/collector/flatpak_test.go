@@ -0,0 +1,35 @@
+package collector
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/R4VXN/os-updates-exporter/internal/runner/runnertest"
+)
+
+func TestCollectFlatpak(t *testing.T) {
+	run, err := runnertest.Parse(`$ flatpak list --system --columns=application,branch,arch,version
+Application ID	Branch	Arch	Version
+org.mozilla.firefox	stable	x86_64	129.0
+org.freedesktop.Platform	23.08	x86_64
+$ flatpak remote-ls --system --updates --columns=application,branch,arch,version,origin
+org.mozilla.firefox	stable	x86_64	130.0	flathub
+org.freedesktop.Platform	23.08	x86_64		flathub
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	all, pkgs, err := collectFLATPAK(context.Background(), run)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Package{
+		{Name: "org.mozilla.firefox//stable", Arch: "x86_64", InstalledVersion: "129.0", CandidateVersion: "130.0", Repo: "flathub", Class: "bugfix"},
+		// runtimes often have no version
+		{Name: "org.freedesktop.Platform//23.08", Arch: "x86_64", Repo: "flathub", Class: "bugfix"},
+	}
+	if all != 2 || !reflect.DeepEqual(pkgs, want) {
+		t.Errorf("got %d %+v, want %+v", all, pkgs, want)
+	}
+}
@@ -0,0 +1,284 @@
+package collector
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/R4VXN/os-updates-exporter/internal/runner"
+)
+
+// Upgrade is an entry of the package manager history: a transaction that
+// upgraded packages or, for zypper, one installed package or patch.
+type Upgrade struct {
+	Time time.Time
+	// Packages is the number of packages upgraded.
+	Packages int
+	// Security is set if security updates were installed.
+	Security bool
+}
+
+// HistoryResult summarizes the upgrades recorded by a package manager.
+type HistoryResult struct {
+	Valid               bool
+	LastUpgrade         time.Time
+	LastSecurityUpgrade time.Time
+	// Upgraded24h and Upgraded7d count the packages upgraded in the last day
+	// and week.
+	Upgraded24h int
+	Upgraded7d  int
+}
+
+// summarizeHistory folds the upgrades into a HistoryResult as of now.
+func summarizeHistory(ups []Upgrade, now time.Time) HistoryResult {
+	h := HistoryResult{Valid: true}
+	for _, u := range ups {
+		if u.Time.After(h.LastUpgrade) {
+			h.LastUpgrade = u.Time
+		}
+		if u.Security && u.Time.After(h.LastSecurityUpgrade) {
+			h.LastSecurityUpgrade = u.Time
+		}
+		if age := now.Sub(u.Time); age >= 0 {
+			if age < 24*time.Hour {
+				h.Upgraded24h += u.Packages
+			}
+			if age < 7*24*time.Hour {
+				h.Upgraded7d += u.Packages
+			}
+		}
+	}
+	return h
+}
+
+// aptHistory reads /var/log/apt/history.log and its rotations (history.log.1,
+// history.log.2.gz, ...). A transaction counts as security if one of the new
+// versions is published in a security archive of the apt lists. Lists that
+// cannot be read only leave their versions unclassified: the history itself
+// does not depend on them.
+func aptHistory(env *Env) ([]Upgrade, error) {
+	files, _ := filepath.Glob(env.Path("/var/log/apt/history.log*"))
+	type txn struct {
+		up       Upgrade
+		versions map[string]string // name:arch -> new version
+	}
+	txns := []txn{}
+	names := map[string]bool{}
+	for _, f := range files {
+		r, err := openHistoryLog(f)
+		if err != nil {
+			return nil, err
+		}
+		var cur *txn
+		sc := bufio.NewScanner(r)
+		sc.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+		for sc.Scan() {
+			key, val, ok := strings.Cut(sc.Text(), ": ")
+			if !ok {
+				continue
+			}
+			switch key {
+			case "Start-Date":
+				// "2024-08-14  06:12:40", local time
+				t, err := time.ParseInLocation("2006-01-02 15:04:05", strings.Join(strings.Fields(val), " "), time.Local)
+				if err != nil {
+					cur = nil
+					continue
+				}
+				txns = append(txns, txn{up: Upgrade{Time: t}, versions: map[string]string{}})
+				cur = &txns[len(txns)-1]
+			case "Upgrade":
+				if cur == nil {
+					continue
+				}
+				for _, m := range aptHistoryPackage.FindAllStringSubmatch(val, -1) {
+					cur.versions[m[1]] = m[3]
+					cur.up.Packages++
+					name, _, _ := strings.Cut(m[1], ":")
+					names[name] = true
+				}
+			}
+		}
+		_ = r.Close()
+		if err := sc.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	avail, _ := readAptIndexes(env.Path("/var/lib/apt/lists"), names)
+	out := []Upgrade{}
+	for _, t := range txns {
+		if t.up.Packages == 0 {
+			continue
+		}
+		for pkg, ver := range t.versions {
+			for _, a := range avail[pkg] {
+				if a.Version == ver && a.Index.Release.security() {
+					t.up.Security = true
+				}
+			}
+		}
+		out = append(out, t.up)
+	}
+	return out, nil
+}
+
+// aptHistoryPackage matches "name:arch (old, new)" in an Upgrade line.
+var aptHistoryPackage = regexp.MustCompile(`([^\s,]+) \(([^,()]+), ([^,()]+)\)`)
+
+// openHistoryLog opens a log file, transparently decompressing .gz rotations.
+func openHistoryLog(file string) (io.ReadCloser, error) {
+	fd, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(file, ".gz") {
+		return fd, nil
+	}
+	zr, err := gzip.NewReader(fd)
+	if err != nil {
+		_ = fd.Close()
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{zr, fd}, nil
+}
+
+// rpmHistory parses dnf/yum history list. yum lists the login user instead of
+// the command line, so its transactions are never classified as security.
+func rpmHistory(ctx context.Context, r runner.Runner, bin string, args ...string) ([]Upgrade, error) {
+	res := r.Run(ctx, bin, args...)
+	if err := res.Check(); err != nil {
+		return nil, err
+	}
+	return parseRPMHistory(res.Stdout), nil
+}
+
+// parseRPMHistory reads the table of dnf/yum history list:
+//
+//	ID     | Command line             | Date and time    | Action(s)      | Altered
+//	     9 | upgrade --security -y    | 2024-08-02 04:10 | Upgrade        |    4
+func parseRPMHistory(out string) []Upgrade {
+	cols := map[string]int{}
+	ups := []Upgrade{}
+	for _, ln := range strings.Split(out, "\n") {
+		fields := strings.Split(ln, "|")
+		if len(fields) < 4 {
+			continue
+		}
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+		if fields[0] == "ID" {
+			for i, f := range fields {
+				cols[f] = i
+			}
+			continue
+		}
+		date, action, altered := historyField(fields, cols, "Date and time"), historyField(fields, cols, "Action(s)"), historyField(fields, cols, "Altered")
+		if !rpmHistoryUpgrade(action) {
+			continue
+		}
+		t, err := time.ParseInLocation("2006-01-02 15:04", date, time.Local)
+		if err != nil {
+			continue
+		}
+		n := 0
+		if f := strings.Fields(altered); len(f) > 0 {
+			n = atoiSafe(f[0])
+		}
+		cmd := " " + historyField(fields, cols, "Command line") + " "
+		sec := false
+		for _, opt := range []string{" --security ", " --advisory", " --advisories", " --cve", " --sec-severity", " --secseverity"} {
+			if strings.Contains(cmd, opt) {
+				sec = true
+			}
+		}
+		ups = append(ups, Upgrade{Time: t, Packages: n, Security: sec})
+	}
+	return ups
+}
+
+func historyField(fields []string, cols map[string]int, name string) string {
+	i, ok := cols[name]
+	if !ok || i >= len(fields) {
+		return ""
+	}
+	return fields[i]
+}
+
+// rpmHistoryUpgrade reports whether a Action(s) column includes an upgrade:
+// "Upgrade", "Update" (yum) or "U" in a list of abbreviations ("I, U").
+func rpmHistoryUpgrade(action string) bool {
+	for _, a := range strings.Split(action, ",") {
+		switch strings.TrimSpace(a) {
+		case "Upgrade", "Update", "Upgraded", "Updated", "U":
+			return true
+		}
+	}
+	return false
+}
+
+// zyppHistory reads /var/log/zypp/history. Upgrades are logged as installs of
+// the new version, so an install counts only if the log has an earlier install
+// of the same package that was not removed since; packages installed before
+// the log starts are not counted. Applied security patches mark the security
+// upgrades.
+//
+//	2024-08-02 04:10:11|install|openssl-3|3.1.4-150600.5.10.1|x86_64|root@host|SLE-Module-Basesystem15-SP6-Updates|...|
+//	2024-08-02 04:10:12|patch  |SUSE-SLE-Module-Basesystem-15-SP6-2024-2871|1|noarch|SLE-Module-Basesystem15-SP6-Updates|important|security|needed|applied|
+//	2024-08-03 09:00:00|remove |vim-small|9.1.0330-150500.20.9.1|x86_64|root@host|
+func zyppHistory(env *Env) ([]Upgrade, error) {
+	fd, err := os.Open(env.Path("/var/log/zypp/history"))
+	if os.IsNotExist(err) {
+		return []Upgrade{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+	out := []Upgrade{}
+	installed := map[string]bool{} // name.arch
+	sc := bufio.NewScanner(fd)
+	sc.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for sc.Scan() {
+		fields := strings.Split(strings.TrimSuffix(sc.Text(), "|"), "|")
+		if len(fields) < 3 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		t, err := time.ParseInLocation("2006-01-02 15:04:05", fields[0], time.Local)
+		if err != nil {
+			continue
+		}
+		switch strings.TrimSpace(fields[1]) {
+		case "install":
+			if len(fields) < 5 {
+				continue
+			}
+			key := fields[2] + "." + fields[4]
+			if installed[key] {
+				out = append(out, Upgrade{Time: t, Packages: 1})
+			}
+			installed[key] = true
+		case "remove":
+			if len(fields) >= 5 {
+				delete(installed, fields[2]+"."+fields[4])
+			}
+		case "patch":
+			// ...|category|old status|new status
+			n := len(fields)
+			if n >= 5 && fields[n-3] == "security" && (fields[n-1] == "applied" || fields[n-1] == "installed") {
+				out = append(out, Upgrade{Time: t, Security: true})
+			}
+		}
+	}
+	return out, sc.Err()
+}
@@ -0,0 +1,76 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAptHistoryUnreadableList(t *testing.T) {
+	root := t.TempDir()
+	logs := filepath.Join(root, "var", "log", "apt")
+	lists := filepath.Join(root, "var", "lib", "apt", "lists")
+	for _, d := range []string{logs, lists} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b, err := os.ReadFile(filepath.Join("testdata", "ubuntu-24.04", "root", "var", "log", "apt", "history.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(logs, "history.log"), b, 0644); err != nil {
+		t.Fatal(err)
+	}
+	xz := "security.ubuntu.com_ubuntu_dists_noble-security_main_binary-amd64_Packages.xz"
+	if err := os.WriteFile(filepath.Join(lists, xz), []byte("\xfd7zXZ\x00"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ups, err := aptHistory(&Env{Root: root})
+	if err != nil {
+		t.Fatalf("aptHistory: %v", err)
+	}
+	// the install transaction upgrades nothing
+	if len(ups) != 1 || ups[0].Packages != 2 {
+		t.Fatalf("upgrades = %+v, want one of 2 packages", ups)
+	}
+	if ups[0].Security {
+		t.Error("upgrade classified as security without a readable list")
+	}
+}
+
+func TestZyppHistory(t *testing.T) {
+	ups, err := zyppHistory(&Env{Root: filepath.Join("testdata", "sles-15", "root")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkgs, security := 0, 0
+	for _, u := range ups {
+		pkgs += u.Packages
+		if u.Security {
+			security++
+		}
+	}
+	// openssl-3, libopenssl3, vim and vim-data-common had an earlier version;
+	// the first installs and the new libsodium23 are not upgrades
+	if pkgs != 4 || security != 1 {
+		t.Errorf("upgraded packages/security patches = %d/%d, want 4/1", pkgs, security)
+	}
+
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "var", "log", "zypp"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	// a reinstall after a removal is not an upgrade
+	log := `2024-06-03 12:00:03|install|vim|9.1.0330-150500.20.9.1|x86_64|root@host|repo-oss|
+2024-07-01 08:00:00|remove |vim|9.1.0330-150500.20.9.1|x86_64|root@host|
+2024-08-19 10:15:44|install|vim|9.1.0330-150500.20.12.1|x86_64|root@host|repo-update|
+`
+	if err := os.WriteFile(filepath.Join(root, "var", "log", "zypp", "history"), []byte(log), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if ups, err := zyppHistory(&Env{Root: root}); err != nil || len(ups) != 0 {
+		t.Errorf("upgrades = %+v, %v, want none", ups, err)
+	}
+}
@@ -0,0 +1,45 @@
+package collector
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// LoadKEV reads the CISA known exploited vulnerabilities catalog
+// (known_exploited_vulnerabilities.json) and returns its CVE IDs.
+func LoadKEV(file string) (map[string]bool, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var catalog struct {
+		Vulnerabilities []struct {
+			CVEID string `json:"cveID"`
+		} `json:"vulnerabilities"`
+	}
+	if err := json.Unmarshal(b, &catalog); err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	kev := map[string]bool{}
+	for _, v := range catalog.Vulnerabilities {
+		if id := strings.ToUpper(strings.TrimSpace(v.CVEID)); id != "" {
+			kev[id] = true
+		}
+	}
+	return kev, nil
+}
+
+// KEVCVEs returns the sorted CVE IDs of cves that are in the catalog.
+func KEVCVEs(cves map[string]string, kev map[string]bool) []string {
+	out := []string{}
+	for id := range cves {
+		if kev[id] {
+			out = append(out, id)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
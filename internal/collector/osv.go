@@ -0,0 +1,350 @@
+package collector
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/R4VXN/os-updates-exporter/internal/version"
+)
+
+// VulnResult counts the known vulnerabilities of the installed packages,
+// whether or not an update is available.
+type VulnResult struct {
+	// Packages counts the affected packages by the highest severity of their
+	// vulnerabilities (source packages on Debian/Ubuntu, binary rpms elsewhere).
+	Packages map[string]int
+	// Vulns counts the distinct vulnerabilities (by CVE ID where known) by
+	// severity.
+	Vulns map[string]int
+}
+
+// osvEntry holds the fields of an OSV record (https://ossf.github.io/osv-schema/)
+// used for matching.
+type osvEntry struct {
+	ID               string         `json:"id"`
+	Aliases          []string       `json:"aliases"`
+	Upstream         []string       `json:"upstream"`
+	Withdrawn        string         `json:"withdrawn"`
+	Severity         []osvSeverity  `json:"severity"`
+	Affected         []osvAffected  `json:"affected"`
+	DatabaseSpecific map[string]any `json:"database_specific"`
+}
+
+type osvSeverity struct {
+	Type  string `json:"type"`
+	Score string `json:"score"`
+}
+
+type osvAffected struct {
+	Package struct {
+		Ecosystem string `json:"ecosystem"`
+		Name      string `json:"name"`
+	} `json:"package"`
+	Ranges []struct {
+		Type   string     `json:"type"`
+		Events []osvEvent `json:"events"`
+	} `json:"ranges"`
+	Versions          []string       `json:"versions"`
+	Severity          []osvSeverity  `json:"severity"`
+	EcosystemSpecific map[string]any `json:"ecosystem_specific"`
+	DatabaseSpecific  map[string]any `json:"database_specific"`
+}
+
+type osvEvent struct {
+	Introduced   string `json:"introduced"`
+	Fixed        string `json:"fixed"`
+	LastAffected string `json:"last_affected"`
+}
+
+// MatchOSV matches the OSV records below env.Cfg.OSVDir (*.json files and the
+// all.zip archives of the osv.dev bulk download) against the installed
+// packages. ok is false if the distribution has no OSV ecosystem (Debian,
+// Ubuntu, AlmaLinux, Rocky Linux, SLES and openSUSE Leap are supported).
+func MatchOSV(ctx context.Context, env *Env, res Result) (vr VulnResult, ok bool, err error) {
+	eco := osvEcosystem(res.OSID, res.OSVersion)
+	if eco == nil {
+		return VulnResult{}, false, nil
+	}
+	pkgs, cmp, err := installedForVulns(ctx, env, res.OSID)
+	if err != nil {
+		return VulnResult{}, true, err
+	}
+	pkgSev, vulnSev := map[string]string{}, map[string]string{}
+	err = walkOSV(ctx, env.Cfg.OSVDir, func(e osvEntry) {
+		if e.Withdrawn != "" {
+			return
+		}
+		for _, a := range e.Affected {
+			if !eco(a.Package.Ecosystem) {
+				continue
+			}
+			for _, v := range pkgs[a.Package.Name] {
+				if !a.affects(v, cmp) {
+					continue
+				}
+				sev := e.severityOf(a)
+				pkgSev[a.Package.Name] = maxSeverity(pkgSev[a.Package.Name], sev)
+				vulnSev[e.key()] = maxSeverity(vulnSev[e.key()], sev)
+			}
+		}
+	})
+	return newVulnResult(pkgSev, vulnSev), true, err
+}
+
+func newVulnResult(pkgSev, vulnSev map[string]string) VulnResult {
+	vr := VulnResult{Packages: map[string]int{}, Vulns: map[string]int{}}
+	for _, sev := range pkgSev {
+		vr.Packages[sev]++
+	}
+	for _, sev := range vulnSev {
+		vr.Vulns[sev]++
+	}
+	return vr
+}
+
+// osvEcosystem returns a matcher for the OSV ecosystems of a release, e.g.
+// "Debian:12", "Ubuntu:22.04:LTS", "AlmaLinux:9", "Rocky Linux:9",
+// "SUSE:Linux Enterprise Server 15 SP6" (and its modules) or
+// "openSUSE:Leap 15.6"; nil if there are none.
+func osvEcosystem(id, versionID string) func(string) bool {
+	major, minor, _ := strings.Cut(versionID, ".")
+	if major == "" {
+		return nil
+	}
+	switch id {
+	case "debian":
+		return func(e string) bool { return e == "Debian:"+major }
+	case "ubuntu":
+		return func(e string) bool {
+			f := strings.Split(e, ":")
+			for _, v := range f[1:] {
+				if f[0] == "Ubuntu" && v == versionID {
+					return true
+				}
+			}
+			return false
+		}
+	case "almalinux":
+		return func(e string) bool { return e == "AlmaLinux:"+major }
+	case "rocky":
+		return func(e string) bool { return e == "Rocky Linux:"+major }
+	case "sles", "sles_sap":
+		suffix := " " + major
+		if minor != "" && minor != "0" {
+			suffix += " SP" + minor
+		}
+		return func(e string) bool {
+			return strings.HasPrefix(e, "SUSE:Linux Enterprise ") && strings.HasSuffix(e, suffix)
+		}
+	case "opensuse-leap":
+		return func(e string) bool { return e == "openSUSE:Leap "+versionID }
+	}
+	return nil
+}
+
+// installedForVulns returns the installed versions per package name as used
+// by the vulnerability feeds, and the version comparison of the distribution.
+// Debian and Ubuntu feeds name source packages; rpm feeds name binary
+// packages.
+func installedForVulns(ctx context.Context, env *Env, osID string) (map[string][]string, func(a, b string) int, error) {
+	out := map[string][]string{}
+	add := func(name, ver string) {
+		for _, v := range out[name] {
+			if v == ver {
+				return
+			}
+		}
+		out[name] = append(out[name], ver)
+	}
+	switch osID {
+	case "debian", "ubuntu":
+		installed, err := readDpkgStatus(env.Path("/var/lib/dpkg/status"))
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, in := range installed {
+			add(in.Source, in.SourceVersion)
+		}
+		return out, version.CompareDeb, nil
+	default:
+		for na, evr := range rpmInstalled(ctx, env.Run) {
+			if i := strings.LastIndex(na, "."); i > 0 {
+				add(na[:i], evr)
+			}
+		}
+		return out, compareRPMFeed, nil
+	}
+}
+
+// compareRPMFeed compares an installed EVR with a feed version. Feeds often
+// leave out the epoch; the installed epoch is then ignored as well.
+func compareRPMFeed(installed, feed string) int {
+	if !strings.Contains(feed, ":") {
+		if _, rest, ok := strings.Cut(installed, ":"); ok {
+			installed = rest
+		}
+	}
+	return version.CompareRPM(installed, feed)
+}
+
+// affects reports whether version v is in a.Versions or in one of its
+// ECOSYSTEM ranges.
+func (a osvAffected) affects(v string, cmp func(a, b string) int) bool {
+	for _, x := range a.Versions {
+		if x == v {
+			return true
+		}
+	}
+	for _, r := range a.Ranges {
+		if r.Type == "ECOSYSTEM" && osvRangeAffects(r.Events, v, cmp) {
+			return true
+		}
+	}
+	return false
+}
+
+// osvRangeAffects evaluates the events of a range in version order: the last
+// event at or below v decides.
+func osvRangeAffects(events []osvEvent, v string, cmp func(a, b string) int) bool {
+	at := func(e osvEvent) string {
+		switch {
+		case e.Introduced != "":
+			return e.Introduced
+		case e.Fixed != "":
+			return e.Fixed
+		default:
+			return e.LastAffected
+		}
+	}
+	evs := append([]osvEvent(nil), events...)
+	sort.SliceStable(evs, func(i, j int) bool {
+		a, b := at(evs[i]), at(evs[j])
+		if a == "0" || b == "0" {
+			return a == "0" && b != "0"
+		}
+		return cmp(a, b) < 0
+	})
+	affected := false
+	for _, e := range evs {
+		switch {
+		case e.Introduced != "":
+			if e.Introduced == "0" || cmp(v, e.Introduced) >= 0 {
+				affected = true
+			}
+		case e.Fixed != "":
+			if cmp(v, e.Fixed) >= 0 {
+				affected = false
+			}
+		case e.LastAffected != "":
+			if cmp(v, e.LastAffected) > 0 {
+				affected = false
+			}
+		}
+	}
+	return affected
+}
+
+// key identifies the vulnerability across feeds: its CVE ID if known.
+func (e osvEntry) key() string {
+	if strings.HasPrefix(e.ID, "CVE-") {
+		return e.ID
+	}
+	for _, id := range append(append([]string{}, e.Upstream...), e.Aliases...) {
+		if strings.HasPrefix(id, "CVE-") {
+			return id
+		}
+	}
+	return e.ID
+}
+
+// severityOf returns the severity of the record for one affected package:
+// a vendor rating (Debian urgency, Ubuntu priority, database severity) or the
+// CVSS v3 base score.
+func (e osvEntry) severityOf(a osvAffected) string {
+	for _, m := range []map[string]any{a.EcosystemSpecific, a.DatabaseSpecific, e.DatabaseSpecific} {
+		for _, k := range []string{"severity", "urgency", "priority"} {
+			if s, ok := m[k].(string); ok {
+				if sev := normalizeSeverity(s); sev != "unknown" {
+					return sev
+				}
+			}
+		}
+	}
+	for _, s := range append(append([]osvSeverity{}, a.Severity...), e.Severity...) {
+		if strings.HasPrefix(s.Type, "CVSS_V3") {
+			if score, ok := cvss3Score(s.Score); ok {
+				return cvssSeverity(score)
+			}
+			continue
+		}
+		if sev := normalizeSeverity(s.Score); sev != "unknown" {
+			return sev
+		}
+	}
+	return "unknown"
+}
+
+// walkOSV calls fn for every record of the *.json files and *.zip archives
+// below dir. It stops with ctx.Err() once ctx is done.
+func walkOSV(ctx context.Context, dir string, fn func(osvEntry)) error {
+	if _, err := os.Stat(dir); err != nil {
+		return err
+	}
+	decode := func(name string, r io.Reader) error {
+		var e osvEntry
+		if err := json.NewDecoder(r).Decode(&e); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		fn(e)
+		return nil
+	}
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		switch {
+		case strings.HasSuffix(path, ".json"):
+			fd, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer fd.Close()
+			return decode(path, fd)
+		case strings.HasSuffix(path, ".zip"):
+			zr, err := zip.OpenReader(path)
+			if err != nil {
+				return err
+			}
+			defer zr.Close()
+			for _, f := range zr.File {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+				if !strings.HasSuffix(f.Name, ".json") {
+					continue
+				}
+				r, err := f.Open()
+				if err != nil {
+					return err
+				}
+				err = decode(path+":"+f.Name, r)
+				_ = r.Close()
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,146 @@
+package collector
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/R4VXN/os-updates-exporter/internal/config"
+	"github.com/R4VXN/os-updates-exporter/internal/runner/runnertest"
+	"github.com/R4VXN/os-updates-exporter/internal/version"
+)
+
+func TestMatchOSV(t *testing.T) {
+	// ubuntu-24.04: openssl is below the fix of CVE-2024-5535 but has the fix
+	// of CVE-2024-2511, curl has no fix; the 22.04 and withdrawn records don't
+	// count. sles-15: the kernel and both openssl-3 binaries are affected.
+	want := map[string]VulnResult{
+		"ubuntu-24.04": {
+			Packages: map[string]int{"important": 1, "moderate": 1},
+			Vulns:    map[string]int{"important": 1, "moderate": 1},
+		},
+		"sles-15": {
+			Packages: map[string]int{"important": 1, "moderate": 2},
+			Vulns:    map[string]int{"important": 1, "moderate": 1},
+		},
+	}
+	zipped := filepath.Join(t.TempDir(), "all.zip")
+	writeZip(t, zipped, filepath.Join("testdata", "ubuntu-24.04", "osv"))
+
+	for _, tc := range []struct {
+		name, dir, osvDir string
+		res               Result
+	}{
+		{name: "ubuntu-24.04", dir: "ubuntu-24.04", res: Result{OSID: "ubuntu", OSVersion: "24.04"}},
+		{name: "ubuntu-24.04-zip", dir: "ubuntu-24.04", osvDir: filepath.Dir(zipped), res: Result{OSID: "ubuntu", OSVersion: "24.04"}},
+		{name: "sles-15", dir: "sles-15", res: Result{OSID: "sles", OSVersion: "15.6"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			run, err := runnertest.Load(filepath.Join("testdata", tc.dir, "commands.txt"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tc.osvDir == "" {
+				tc.osvDir = filepath.Join("testdata", tc.dir, "osv")
+			}
+			env := &Env{Cfg: config.Config{OSVDir: tc.osvDir}, Run: run, Root: filepath.Join("testdata", tc.dir, "root")}
+			vr, ok, err := MatchOSV(context.Background(), env, tc.res)
+			if err != nil || !ok {
+				t.Fatalf("MatchOSV: %t %v", ok, err)
+			}
+			if !reflect.DeepEqual(vr, want[tc.dir]) {
+				t.Errorf("got %+v, want %+v", vr, want[tc.dir])
+			}
+		})
+	}
+
+	env := &Env{Cfg: config.Config{OSVDir: "testdata"}}
+	if _, ok, _ := MatchOSV(context.Background(), env, Result{OSID: "alpine", OSVersion: "3.20.3"}); ok {
+		t.Error("matched a distribution without OSV ecosystem")
+	}
+}
+
+func TestWalkOSVCanceled(t *testing.T) {
+	zipped := filepath.Join(t.TempDir(), "all.zip")
+	writeZip(t, zipped, filepath.Join("testdata", "ubuntu-24.04", "osv"))
+	for _, dir := range []string{filepath.Join("testdata", "ubuntu-24.04", "osv"), filepath.Dir(zipped)} {
+		// the deadline passes while the first record is handled
+		ctx, cancel := context.WithCancel(context.Background())
+		n := 0
+		err := walkOSV(ctx, dir, func(osvEntry) {
+			n++
+			cancel()
+		})
+		if !errors.Is(err, context.Canceled) || n != 1 {
+			t.Errorf("%s: %d records, %v", dir, n, err)
+		}
+	}
+}
+
+func writeZip(t *testing.T, file, dir string) {
+	t.Helper()
+	fd, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fd.Close()
+	zw := zip.NewWriter(fd)
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		b, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		w, err := zw.Create(e.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = w.Write(b)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOSVRangeAffects(t *testing.T) {
+	// events out of order; a second range starts after last_affected
+	events := []osvEvent{{LastAffected: "1.4-1"}, {Introduced: "0"}, {Introduced: "2.0-1"}, {Fixed: "2.1-1"}}
+	for v, want := range map[string]bool{
+		"1.0-1": true,
+		"1.4-1": true,
+		"1.5-1": false,
+		"2.0-3": true,
+		"2.1-1": false,
+		"3.0-1": false,
+	} {
+		if got := osvRangeAffects(events, v, version.CompareDeb); got != want {
+			t.Errorf("%s: got %t, want %t", v, got, want)
+		}
+	}
+	// feed versions without epoch
+	if !osvRangeAffects([]osvEvent{{Introduced: "0"}, {Fixed: "3.0.7-28.el9"}}, "1:3.0.7-27.el9", compareRPMFeed) {
+		t.Error("epoch of the installed version not ignored")
+	}
+}
+
+func TestCVSS3Score(t *testing.T) {
+	for vector, want := range map[string]float64{
+		"CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H": 9.8,
+		"CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:C/C:H/I:H/A:H": 10,
+		"CVSS:3.1/AV:L/AC:L/PR:L/UI:N/S:U/C:H/I:H/A:H": 7.8,
+		"CVSS:3.0/AV:N/AC:L/PR:L/UI:R/S:C/C:L/I:L/A:N": 5.4,
+		"CVSS:3.1/AV:N/AC:H/PR:N/UI:N/S:U/C:N/I:N/A:H": 5.9,
+		"CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:N/I:N/A:N": 0,
+	} {
+		if got, ok := cvss3Score(vector); !ok || got != want {
+			t.Errorf("%s = %v %t, want %v", vector, got, ok, want)
+		}
+	}
+	if _, ok := cvss3Score("AV:N/AC:L/Au:N/C:P/I:P/A:P"); ok {
+		t.Error("scored a CVSS v2 vector")
+	}
+}
@@ -0,0 +1,376 @@
+package collector
+
+import (
+	"compress/bzip2"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/R4VXN/os-updates-exporter/internal/version"
+)
+
+// ovalDocument holds the parts of an OVAL definitions file
+// (https://oval.mitre.org) used for evaluation. Elements are matched by local
+// name, so the oval-def and linux-def namespaces need no special handling.
+type ovalDocument struct {
+	Definitions []ovalDefinition `xml:"definitions>definition"`
+	Tests       struct {
+		Items []ovalTest `xml:",any"`
+	} `xml:"tests"`
+	Objects struct {
+		Items []ovalObject `xml:",any"`
+	} `xml:"objects"`
+	States struct {
+		Items []ovalState `xml:",any"`
+	} `xml:"states"`
+}
+
+type ovalDefinition struct {
+	ID        string       `xml:"id,attr"`
+	Platforms []string     `xml:"metadata>affected>platform"`
+	Severity  string       `xml:"metadata>advisory>severity"`
+	Criteria  ovalCriteria `xml:"criteria"`
+}
+
+type ovalCriteria struct {
+	Operator   string         `xml:"operator,attr"`
+	Negate     bool           `xml:"negate,attr"`
+	Criteria   []ovalCriteria `xml:"criteria"`
+	Criterions []struct {
+		TestRef string `xml:"test_ref,attr"`
+		Negate  bool   `xml:"negate,attr"`
+	} `xml:"criterion"`
+	Extends []struct {
+		DefinitionRef string `xml:"definition_ref,attr"`
+		Negate        bool   `xml:"negate,attr"`
+	} `xml:"extend_definition"`
+}
+
+type ovalTest struct {
+	XMLName xml.Name
+	ID      string `xml:"id,attr"`
+	Check   string `xml:"check,attr"`
+	Object  struct {
+		Ref string `xml:"object_ref,attr"`
+	} `xml:"object"`
+	States []struct {
+		Ref string `xml:"state_ref,attr"`
+	} `xml:"state"`
+}
+
+type ovalObject struct {
+	ID   string `xml:"id,attr"`
+	Name string `xml:"name"`
+}
+
+type ovalState struct {
+	ID      string     `xml:"id,attr"`
+	EVR     *ovalValue `xml:"evr"`
+	Version *ovalValue `xml:"version"`
+	Arch    *ovalValue `xml:"arch"`
+}
+
+type ovalValue struct {
+	Operation string `xml:"operation,attr"`
+	Value     string `xml:",chardata"`
+}
+
+// rpmPackage is one installed instance of an rpm.
+type rpmPackage struct {
+	Arch string
+	EVR  string // [epoch:]version-release
+}
+
+// EvaluateOVAL evaluates the OVAL definitions below env.Cfg.OVALDir (*.xml,
+// *.xml.gz, *.xml.bz2) against the rpm database and counts the affected
+// definitions by severity. Only definitions for the release detected from
+// os-release (detectOS) are evaluated; ok is false if the release has no OVAL
+// platform (RHEL, AlmaLinux, Rocky Linux, Oracle Linux, SLES and openSUSE
+// Leap are supported).
+//
+// rpminfo tests compare name, arch, version and evr; signature keys are not
+// checked. Other tests (rpmverifyfile, uname, textfilecontent, ...) only
+// establish the release and count as true.
+func EvaluateOVAL(ctx context.Context, env *Env, res Result) (affected map[string]int, ok bool, err error) {
+	platform := ovalPlatform(res.OSID, res.OSVersion)
+	if platform == nil {
+		return nil, false, nil
+	}
+	installed := map[string][]rpmPackage{}
+	for na, evr := range rpmInstalled(ctx, env.Run) {
+		if i := strings.LastIndex(na, "."); i > 0 {
+			installed[na[:i]] = append(installed[na[:i]], rpmPackage{Arch: na[i+1:], EVR: evr})
+		}
+	}
+	affected = map[string]int{}
+	err = walkOVAL(env.Cfg.OVALDir, func(doc *ovalDocument) {
+		ev := newOVALEvaluator(doc, installed)
+		for _, d := range doc.Definitions {
+			if !ovalApplies(d, platform) {
+				continue
+			}
+			if ev.definition(d.ID) {
+				affected[normalizeSeverity(d.Severity)]++
+			}
+		}
+	})
+	return affected, true, err
+}
+
+// ovalPlatform returns a matcher for the OVAL platform names of a release, e.g.
+// "Red Hat Enterprise Linux 9", "SUSE Linux Enterprise Server 15 SP6" (and the
+// other SLE products of the service pack) or "openSUSE Leap 15.6"; nil if
+// there are none.
+func ovalPlatform(id, versionID string) func(string) bool {
+	major, minor, _ := strings.Cut(versionID, ".")
+	if major == "" {
+		return nil
+	}
+	named := func(name string) func(string) bool {
+		return func(p string) bool { return p == name || strings.HasPrefix(p, name+" ") }
+	}
+	switch id {
+	case "rhel":
+		return named("Red Hat Enterprise Linux " + major)
+	case "almalinux":
+		return named("AlmaLinux " + major)
+	case "rocky":
+		return named("Rocky Linux " + major)
+	case "ol":
+		return named("Oracle Linux " + major)
+	case "sles", "sles_sap":
+		suffix := " " + major
+		if minor != "" && minor != "0" {
+			suffix += " SP" + minor
+		}
+		return func(p string) bool {
+			return strings.HasPrefix(p, "SUSE Linux Enterprise ") && strings.HasSuffix(p, suffix)
+		}
+	case "opensuse-leap":
+		return named("openSUSE Leap " + versionID)
+	}
+	return nil
+}
+
+func ovalApplies(d ovalDefinition, platform func(string) bool) bool {
+	for _, p := range d.Platforms {
+		if platform(strings.TrimSpace(p)) {
+			return true
+		}
+	}
+	return false
+}
+
+// ovalEvaluator evaluates the definitions of one document, caching the results
+// of definitions and tests (definitions may extend each other).
+type ovalEvaluator struct {
+	installed   map[string][]rpmPackage
+	definitions map[string]*ovalDefinition
+	tests       map[string]*ovalTest
+	objects     map[string]*ovalObject
+	states      map[string]*ovalState
+	results     map[string]bool
+}
+
+func newOVALEvaluator(doc *ovalDocument, installed map[string][]rpmPackage) *ovalEvaluator {
+	ev := &ovalEvaluator{
+		installed:   installed,
+		definitions: map[string]*ovalDefinition{},
+		tests:       map[string]*ovalTest{},
+		objects:     map[string]*ovalObject{},
+		states:      map[string]*ovalState{},
+		results:     map[string]bool{},
+	}
+	for i, d := range doc.Definitions {
+		ev.definitions[d.ID] = &doc.Definitions[i]
+	}
+	for i, t := range doc.Tests.Items {
+		ev.tests[t.ID] = &doc.Tests.Items[i]
+	}
+	for i, o := range doc.Objects.Items {
+		ev.objects[o.ID] = &doc.Objects.Items[i]
+	}
+	for i, s := range doc.States.Items {
+		ev.states[s.ID] = &doc.States.Items[i]
+	}
+	return ev
+}
+
+func (ev *ovalEvaluator) definition(id string) bool {
+	if r, ok := ev.results[id]; ok {
+		return r
+	}
+	ev.results[id] = false // breaks reference cycles
+	d := ev.definitions[id]
+	r := d != nil && ev.criteria(d.Criteria)
+	ev.results[id] = r
+	return r
+}
+
+func (ev *ovalEvaluator) criteria(c ovalCriteria) bool {
+	var rs []bool
+	for _, sub := range c.Criteria {
+		rs = append(rs, ev.criteria(sub))
+	}
+	for _, cr := range c.Criterions {
+		rs = append(rs, ev.test(cr.TestRef) != cr.Negate)
+	}
+	for _, ext := range c.Extends {
+		rs = append(rs, ev.definition(ext.DefinitionRef) != ext.Negate)
+	}
+	n := 0
+	for _, r := range rs {
+		if r {
+			n++
+		}
+	}
+	var r bool
+	switch strings.ToUpper(c.Operator) {
+	case "OR":
+		r = n > 0
+	case "ONE":
+		r = n == 1
+	case "XOR":
+		r = n%2 == 1
+	default: // AND
+		r = n == len(rs)
+	}
+	return r != c.Negate
+}
+
+func (ev *ovalEvaluator) test(id string) bool {
+	key := "test " + id
+	if r, ok := ev.results[key]; ok {
+		return r
+	}
+	r := true
+	if t := ev.tests[id]; t != nil && t.XMLName.Local == "rpminfo_test" {
+		r = ev.rpminfo(t)
+	}
+	ev.results[key] = r
+	return r
+}
+
+// rpminfo evaluates an rpminfo test: the package must be installed and, per
+// the check attribute, at least one or all of its instances must satisfy the
+// states.
+func (ev *ovalEvaluator) rpminfo(t *ovalTest) bool {
+	obj := ev.objects[t.Object.Ref]
+	if obj == nil {
+		return false
+	}
+	pkgs := ev.installed[strings.TrimSpace(obj.Name)]
+	if len(pkgs) == 0 {
+		return false
+	}
+	n := 0
+	for _, p := range pkgs {
+		matches := true
+		for _, s := range t.States {
+			if st := ev.states[s.Ref]; st != nil && !st.matches(p) {
+				matches = false
+			}
+		}
+		if matches {
+			n++
+		}
+	}
+	if t.Check == "all" {
+		return n == len(pkgs)
+	}
+	return n > 0
+}
+
+func (st *ovalState) matches(p rpmPackage) bool {
+	if st.Arch != nil && !st.Arch.matches(p.Arch, nil) {
+		return false
+	}
+	if st.EVR != nil && !st.EVR.matches(p.EVR, version.CompareRPM) {
+		return false
+	}
+	if st.Version != nil {
+		v := p.EVR
+		if _, rest, ok := strings.Cut(v, ":"); ok {
+			v = rest
+		}
+		if i := strings.LastIndex(v, "-"); i > 0 {
+			v = v[:i]
+		}
+		if !st.Version.matches(v, version.CompareRPM) {
+			return false
+		}
+	}
+	return true
+}
+
+// matches applies the operation of the state value to s; cmp orders versions
+// (nil for plain strings).
+func (v *ovalValue) matches(s string, cmp func(a, b string) int) bool {
+	want := strings.TrimSpace(v.Value)
+	if v.Operation == "pattern match" {
+		re, err := regexp.Compile(want)
+		return err == nil && re.MatchString(s)
+	}
+	c := strings.Compare(s, want)
+	if cmp != nil {
+		c = cmp(s, want)
+	}
+	switch v.Operation {
+	case "not equal":
+		return c != 0
+	case "less than":
+		return c < 0
+	case "less than or equal":
+		return c <= 0
+	case "greater than":
+		return c > 0
+	case "greater than or equal":
+		return c >= 0
+	default: // equals
+		return c == 0
+	}
+}
+
+// walkOVAL calls fn for every OVAL document below dir.
+func walkOVAL(dir string, fn func(*ovalDocument)) error {
+	if _, err := os.Stat(dir); err != nil {
+		return err
+	}
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		if !strings.HasSuffix(path, ".xml") && !strings.HasSuffix(path, ".xml.gz") && !strings.HasSuffix(path, ".xml.bz2") {
+			return nil
+		}
+		fd, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer fd.Close()
+		var r io.Reader = fd
+		switch {
+		case strings.HasSuffix(path, ".gz"):
+			gz, err := gzip.NewReader(fd)
+			if err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+			r = gz
+		case strings.HasSuffix(path, ".bz2"):
+			r = bzip2.NewReader(fd)
+		}
+		doc := &ovalDocument{}
+		if err := xml.NewDecoder(r).Decode(doc); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		fn(doc)
+		return nil
+	})
+}
@@ -0,0 +1,142 @@
+package collector
+
+import (
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/R4VXN/os-updates-exporter/internal/config"
+	"github.com/R4VXN/os-updates-exporter/internal/runner/runnertest"
+)
+
+func TestEvaluateOVAL(t *testing.T) {
+	// rhel-9: openssl and the kernel are affected, glibc has the fix, nginx is
+	// not installed and the bash definition is for RHEL 8. sles-15: the kernel
+	// and openssl-3 patches are needed, timezone is current.
+	gzDir := t.TempDir()
+	b, err := os.ReadFile(filepath.Join("testdata", "rhel-9", "oval", "rhel-9.oval.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fd, err := os.Create(filepath.Join(gzDir, "rhel-9.oval.xml.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := gzip.NewWriter(fd)
+	_, _ = zw.Write(b)
+	_ = zw.Close()
+	_ = fd.Close()
+
+	for _, tc := range []struct {
+		name, dir, ovalDir string
+		res                Result
+		want               map[string]int
+	}{
+		{name: "rhel-9", dir: "rhel-9", res: Result{OSID: "rhel", OSVersion: "9.4"}, want: map[string]int{"important": 2}},
+		{name: "rhel-9-gz", dir: "rhel-9", ovalDir: gzDir, res: Result{OSID: "rhel", OSVersion: "9.4"}, want: map[string]int{"important": 2}},
+		{name: "sles-15", dir: "sles-15", res: Result{OSID: "sles", OSVersion: "15.6"}, want: map[string]int{"important": 1, "moderate": 1}},
+		// the service pack does not match the definitions
+		{name: "sles-15-sp5", dir: "sles-15", res: Result{OSID: "sles", OSVersion: "15.5"}, want: map[string]int{}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			run, err := runnertest.Load(filepath.Join("testdata", tc.dir, "commands.txt"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tc.ovalDir == "" {
+				tc.ovalDir = filepath.Join("testdata", tc.dir, "oval")
+			}
+			env := &Env{Cfg: config.Config{OVALDir: tc.ovalDir}, Run: run}
+			got, ok, err := EvaluateOVAL(context.Background(), env, tc.res)
+			if err != nil || !ok {
+				t.Fatalf("EvaluateOVAL: %t %v", ok, err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestOVALCriteria(t *testing.T) {
+	doc := `<oval_definitions>
+<definitions>
+  <definition id="base"><criteria><criterion test_ref="bash-installed"/></criteria></definition>
+  <definition id="extends"><criteria operator="AND">
+    <extend_definition definition_ref="base"/>
+    <criterion test_ref="vim-old" negate="true"/>
+  </criteria></definition>
+  <definition id="one"><criteria operator="ONE">
+    <criterion test_ref="bash-installed"/>
+    <criterion test_ref="vim-old"/>
+  </criteria></definition>
+  <definition id="all-arches"><criteria><criterion test_ref="glibc-x86_64-all"/></criteria></definition>
+  <definition id="unknown-test"><criteria><criterion test_ref="uname"/></criteria></definition>
+</definitions>
+<tests>
+  <rpminfo_test id="bash-installed"><object object_ref="bash"/></rpminfo_test>
+  <rpminfo_test id="vim-old"><object object_ref="vim"/><state state_ref="old"/></rpminfo_test>
+  <rpminfo_test id="glibc-x86_64-all" check="all"><object object_ref="glibc"/><state state_ref="x86_64"/></rpminfo_test>
+  <uname_test id="uname"/>
+</tests>
+<objects>
+  <rpminfo_object id="bash"><name>bash</name></rpminfo_object>
+  <rpminfo_object id="vim"><name>vim-minimal</name></rpminfo_object>
+  <rpminfo_object id="glibc"><name>glibc</name></rpminfo_object>
+</objects>
+<states>
+  <rpminfo_state id="old"><evr operation="less than">2:8.2.2637-20.el9_1</evr></rpminfo_state>
+  <rpminfo_state id="x86_64"><arch operation="equals">x86_64</arch></rpminfo_state>
+</states>
+</oval_definitions>`
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "test.xml"), []byte(doc), 0644); err != nil {
+		t.Fatal(err)
+	}
+	installed := map[string][]rpmPackage{
+		"bash":        {{Arch: "x86_64", EVR: "5.1.8-9.el9"}},
+		"vim-minimal": {{Arch: "x86_64", EVR: "2:8.2.2637-20.el9_1"}},
+		"glibc":       {{Arch: "x86_64", EVR: "2.34-100.el9"}, {Arch: "i686", EVR: "2.34-100.el9"}},
+	}
+	got := map[string]bool{}
+	err := walkOVAL(dir, func(d *ovalDocument) {
+		ev := newOVALEvaluator(d, installed)
+		for _, def := range d.Definitions {
+			got[def.ID] = ev.definition(def.ID)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]bool{"base": true, "extends": true, "one": true, "all-arches": false, "unknown-test": true}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestOVALPlatform(t *testing.T) {
+	for _, tc := range []struct {
+		id, version, platform string
+		want                  bool
+	}{
+		{"rhel", "9.4", "Red Hat Enterprise Linux 9", true},
+		{"rhel", "8.10", "Red Hat Enterprise Linux 9", false},
+		{"almalinux", "9.4", "AlmaLinux 9", true},
+		{"sles", "15.6", "SUSE Linux Enterprise Server 15 SP6", true},
+		{"sles", "15.6", "SUSE Linux Enterprise Module for Basesystem 15 SP6", true},
+		{"sles", "15.6", "SUSE Linux Enterprise Server 15 SP5", false},
+		{"sles", "15", "SUSE Linux Enterprise Server 15", true},
+		{"opensuse-leap", "15.6", "openSUSE Leap 15.6", true},
+	} {
+		match := ovalPlatform(tc.id, tc.version)
+		if got := match != nil && match(tc.platform); got != tc.want {
+			t.Errorf("%s %s ~ %q = %t, want %t", tc.id, tc.version, tc.platform, got, tc.want)
+		}
+	}
+	if ovalPlatform("ubuntu", "24.04") != nil {
+		t.Error("OVAL platform for a dpkg distribution")
+	}
+}
@@ -0,0 +1,237 @@
+package collector
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/R4VXN/os-updates-exporter/internal/runner"
+)
+
+const pacmanDBPath = "/var/lib/pacman"
+
+type pacmanBackend struct{}
+
+func init() { Register(pacmanBackend{}) }
+
+func (pacmanBackend) Name() string { return "pacman" }
+
+func (pacmanBackend) Detect(env *Env) bool { return env.available("pacman", pacmanDBPath+"/local") }
+
+func (pacmanBackend) CollectPending(ctx context.Context, env *Env) (Pending, error) {
+	all, pkgs, err := collectPACMAN(ctx, env.Run, env.Path(pacmanDBPath))
+	// Arch Linux ships no advisory data with the sync databases.
+	return Pending{All: all, Bugfix: all, Packages: pkgs, Held: parsePacmanIgnorePkg(env.Path("/etc/pacman.conf"))}, err
+}
+
+func (pacmanBackend) ListRepos(ctx context.Context, env *Env) []string {
+	return parsePacmanConf(env, "/etc/pacman.conf")
+}
+
+func (pacmanBackend) MetadataAge(env *Env) float64 {
+	now := time.Now()
+	maxAge := 0.0
+	matches, _ := filepath.Glob(env.Path(filepath.Join(pacmanDBPath, "sync", "*.db")))
+	for _, p := range matches {
+		if st, err := os.Stat(p); err == nil {
+			age := now.Sub(st.ModTime()).Seconds()
+			if age > maxAge {
+				maxAge = age
+			}
+		}
+	}
+	return maxAge
+}
+
+// RebootHint compares the running kernel with the installed kernel packages.
+func (pacmanBackend) RebootHint(ctx context.Context, env *Env) (bool, string) {
+	if !env.Live() {
+		return false, "unknown"
+	}
+	b, err := os.ReadFile("/proc/sys/kernel/osrelease")
+	if err != nil {
+		return false, "unknown"
+	}
+	out := env.Run.Run(ctx, "pacman", "-Q", "--", "linux", "linux-lts", "linux-zen", "linux-hardened").Stdout
+	if pacmanKernelOutdated(strings.TrimSpace(string(b)), out) {
+		return true, "kernel"
+	}
+	return false, "unknown"
+}
+
+func (pacmanBackend) History(ctx context.Context, env *Env) ([]Upgrade, bool, error) {
+	return nil, false, nil
+}
+
+// Best-effort, checkupdates-style: sync a temporary copy of the databases
+// (sharing the local db) so the live sync db is left untouched, then pacman -Qu.
+func collectPACMAN(ctx context.Context, r runner.Runner, dbPath string) (all int, pkgs []Package, err error) {
+	tmp, err := os.MkdirTemp("", "os-updates-exporter-pacman-")
+	if err != nil {
+		return 0, nil, err
+	}
+	defer os.RemoveAll(tmp)
+
+	if err := os.Symlink(filepath.Join(dbPath, "local"), filepath.Join(tmp, "local")); err != nil {
+		return 0, nil, err
+	}
+	if err := os.MkdirAll(filepath.Join(tmp, "sync"), 0755); err != nil {
+		return 0, nil, err
+	}
+	// seed with the current sync dbs so only changed ones are downloaded
+	dbs, _ := filepath.Glob(filepath.Join(dbPath, "sync", "*.db"))
+	for _, db := range dbs {
+		_ = copyFile(db, filepath.Join(tmp, "sync", filepath.Base(db)))
+	}
+
+	if err := r.Run(ctx, "pacman", "-Sy", "--dbpath", tmp, "--logfile", "/dev/null", "--noprogressbar").Check(); err != nil {
+		return 0, nil, err
+	}
+	// -Qu exits 1 if there is nothing to upgrade
+	res := r.Run(ctx, "pacman", "-Qu", "--dbpath", tmp)
+	pkgs = parsePacmanQu(res.Stdout)
+	return len(pkgs), pkgs, res.Check(0, 1)
+}
+
+// parsePacmanQu parses "pacman -Qu" rows: "name 1.0-1 -> 1.1-1 [ignored]".
+// Ignored packages (IgnorePkg/IgnoreGroup) are marked held as pacman -Su will
+// not upgrade them.
+func parsePacmanQu(out string) []Package {
+	pkgs := []Package{}
+	for _, ln := range strings.Split(out, "\n") {
+		fields := strings.Fields(ln)
+		if len(fields) < 4 || fields[2] != "->" {
+			continue
+		}
+		pkgs = append(pkgs, Package{
+			Name:             fields[0],
+			InstalledVersion: fields[1],
+			CandidateVersion: fields[3],
+			Class:            "bugfix",
+			Held:             len(fields) > 4 && fields[4] == "[ignored]",
+		})
+	}
+	return pkgs
+}
+
+// parsePacmanIgnorePkg returns the IgnorePkg entries of the [options] section.
+// Entries may be glob patterns.
+func parsePacmanIgnorePkg(path string) []string {
+	out := []string{}
+	section := ""
+	for _, kv := range readPacmanConf(path) {
+		switch {
+		case kv[0] == "[":
+			section = kv[1]
+		case section == "options" && kv[0] == "IgnorePkg":
+			out = append(out, strings.Fields(kv[1])...)
+		}
+	}
+	return unique(out)
+}
+
+// parsePacmanConf returns the sync db URL of every server of the enabled
+// repositories, following Include files (mirrorlists) and expanding $repo and $arch.
+// path and the Include paths are read below env.Root.
+func parsePacmanConf(env *Env, path string) []string {
+	out := []string{}
+	arch := linuxArch()
+	section := ""
+	for _, kv := range readPacmanConf(env.Path(path)) {
+		key, val := kv[0], kv[1]
+		switch {
+		case key == "[":
+			section = val
+		case section == "options" && key == "Architecture" && val != "auto":
+			if f := strings.Fields(val); len(f) > 0 {
+				arch = f[0]
+			}
+		case section == "" || section == "options":
+		case key == "Server":
+			out = append(out, pacmanServerURL(val, section, arch)...)
+		case key == "Include":
+			for _, inc := range readPacmanConf(env.Path(val)) {
+				if inc[0] == "Server" {
+					out = append(out, pacmanServerURL(inc[1], section, arch)...)
+				}
+			}
+		}
+	}
+	return out
+}
+
+// readPacmanConf returns the key/value pairs of a pacman.conf style file;
+// section headers are returned as {"[", name}.
+func readPacmanConf(path string) [][2]string {
+	kvs := [][2]string{}
+	fd, err := os.Open(path)
+	if err != nil {
+		return kvs
+	}
+	defer fd.Close()
+	sc := bufio.NewScanner(fd)
+	for sc.Scan() {
+		ln := strings.TrimSpace(sc.Text())
+		if ln == "" || strings.HasPrefix(ln, "#") {
+			continue
+		}
+		if strings.HasPrefix(ln, "[") && strings.HasSuffix(ln, "]") {
+			kvs = append(kvs, [2]string{"[", strings.Trim(ln, "[]")})
+			continue
+		}
+		key, val, ok := strings.Cut(ln, "=")
+		if !ok {
+			continue
+		}
+		kvs = append(kvs, [2]string{strings.TrimSpace(key), strings.TrimSpace(val)})
+	}
+	return kvs
+}
+
+func pacmanServerURL(server, repo, arch string) []string {
+	u := strings.NewReplacer("$repo", repo, "$arch", arch).Replace(server)
+	if !strings.HasPrefix(u, "http") {
+		return nil
+	}
+	return []string{strings.TrimSuffix(u, "/") + "/" + repo + ".db"}
+}
+
+// pacmanKernelOutdated reports whether the running kernel release (uname -r,
+// e.g. "6.5.9-arch2-1" or "6.1.60-1-lts") matches none of the installed kernel
+// packages from "pacman -Q" ("linux 6.5.9.arch2-1", "linux-lts 6.1.60-1").
+func pacmanKernelOutdated(running, installed string) bool {
+	norm := func(s string) string { return strings.ReplaceAll(s, "-", ".") }
+	found := false
+	for _, ln := range strings.Split(installed, "\n") {
+		fields := strings.Fields(ln)
+		if len(fields) != 2 {
+			continue
+		}
+		found = true
+		if strings.HasPrefix(norm(running), norm(fields[1])) {
+			return false
+		}
+	}
+	return found
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
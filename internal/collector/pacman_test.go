@@ -0,0 +1,121 @@
+package collector
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/R4VXN/os-updates-exporter/internal/runner"
+	"github.com/R4VXN/os-updates-exporter/internal/runner/runnertest"
+)
+
+func TestParsePacmanConf(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "etc", "pacman.d"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	conf := filepath.Join(root, "etc", "pacman.conf")
+	if err := os.WriteFile(conf, []byte(`[options]
+# an empty value must not stop the parser
+Architecture =
+IgnorePkg = linux linux-headers
+
+[core]
+Server = https://mirror.example.org/$repo/os/x86_64
+
+[extra]
+# read below the root, not from the exporter's filesystem
+Include = /etc/pacman.d/mirrorlist
+
+#[testing]
+#Server = https://mirror.example.org/$repo/os/x86_64
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "etc", "pacman.d", "mirrorlist"), []byte("Server = https://mirror2.example.org/$repo/os/x86_64\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"https://mirror.example.org/core/os/x86_64/core.db",
+		"https://mirror2.example.org/extra/os/x86_64/extra.db",
+	}
+	if got := parsePacmanConf(&Env{Root: root}, "/etc/pacman.conf"); !reflect.DeepEqual(got, want) {
+		t.Errorf("servers = %q, want %q", got, want)
+	}
+	if got, want := parsePacmanIgnorePkg(conf), []string{"linux", "linux-headers"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("IgnorePkg = %q, want %q", got, want)
+	}
+}
+
+func TestParsePacmanQu(t *testing.T) {
+	pkgs := parsePacmanQu("linux 6.10.9.arch1-1 -> 6.10.10.arch1-1 [ignored]\nopenssl 3.3.1-1 -> 3.3.2-1\n")
+	if len(pkgs) != 2 {
+		t.Fatalf("packages = %+v", pkgs)
+	}
+	if !pkgs[0].Held || pkgs[1].Held || pkgs[1].CandidateVersion != "3.3.2-1" {
+		t.Errorf("packages = %+v", pkgs)
+	}
+}
+
+func TestPacmanKernelOutdated(t *testing.T) {
+	for _, tc := range []struct {
+		running, installed string
+		want               bool
+	}{
+		// uname -r "6.10.10-arch1-1" is pkgver "6.10.10.arch1-1"
+		{"6.10.10-arch1-1", "linux 6.10.10.arch1-1\n", false},
+		{"6.10.9-arch1-2", "linux 6.10.10.arch1-1\n", true},
+		// any installed kernel package may be the running one
+		{"6.6.52-1-lts", "linux 6.10.10.arch1-1\nlinux-lts 6.6.52-1\n", false},
+		// nothing installed: not outdated
+		{"6.10.10-arch1-1", "", false},
+	} {
+		if got := pacmanKernelOutdated(tc.running, tc.installed); got != tc.want {
+			t.Errorf("pacmanKernelOutdated(%q, %q) = %v, want %v", tc.running, tc.installed, got, tc.want)
+		}
+	}
+}
+
+// pacmanTmpRunner replays a transcript with the temporary --dbpath of
+// collectPACMAN replaced by "TMP".
+type pacmanTmpRunner struct{ *runnertest.Fake }
+
+func (r pacmanTmpRunner) Run(ctx context.Context, name string, args ...string) runner.Result {
+	args = append([]string(nil), args...)
+	for i := 1; i < len(args); i++ {
+		if args[i-1] == "--dbpath" && strings.Contains(args[i], "os-updates-exporter-pacman-") {
+			args[i] = "TMP"
+		}
+	}
+	return r.Fake.Run(ctx, name, args...)
+}
+
+func TestCollectPacman(t *testing.T) {
+	dbPath := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dbPath, "sync"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	sync := "$ pacman -Sy --dbpath TMP --logfile /dev/null --noprogressbar\n"
+	for _, tc := range []struct {
+		qu   string
+		all  int
+		fail bool
+	}{
+		{"$ pacman -Qu --dbpath TMP\nopenssl 3.3.1-1 -> 3.3.2-1\n", 1, false},
+		// nothing to upgrade
+		{"$ pacman -Qu --dbpath TMP\n? 1\n", 0, false},
+		{"$ pacman -Qu --dbpath TMP\n? 2\n! error: could not open database\n", 0, true},
+	} {
+		fake, err := runnertest.Parse(sync + tc.qu)
+		if err != nil {
+			t.Fatal(err)
+		}
+		all, _, err := collectPACMAN(context.Background(), pacmanTmpRunner{fake}, dbPath)
+		if all != tc.all || (err != nil) != tc.fail {
+			t.Errorf("%q: %d pending, err %v", tc.qu, all, err)
+		}
+	}
+}
@@ -9,24 +9,17 @@ import (
 	"strings"
 	"time"
 
-	"github.com/R4VXN/os-updates-exporter/internal/config"
+	"github.com/R4VXN/os-updates-exporter/internal/runner"
 )
 
-func CheckRepos(ctx context.Context, cfg config.Config, manager string) (RepoResult, error) {
-	urls := []string{}
-	switch manager {
-	case "apt":
-		urls = append(urls, parseAptSources()...)
-	case "dnf", "yum":
-		urls = append(urls, parseYumRepos()...)
-	case "zypper":
-		urls = append(urls, parseZypperRepos(ctx)...)
-	default:
+func CheckRepos(ctx context.Context, env *Env, manager string) (RepoResult, error) {
+	b, ok := Lookup(manager)
+	if !ok {
 		return RepoResult{Valid: false}, nil
 	}
-	urls = unique(urls)
+	urls := unique(b.ListRepos(ctx, env))
 
-	client := &http.Client{Timeout: cfg.RepoHeadTimeout}
+	client := &http.Client{Timeout: env.Cfg.RepoHeadTimeout}
 	total := len(urls)
 	unreach := 0
 	latSum := 0.0
@@ -57,16 +50,16 @@ func CheckRepos(ctx context.Context, cfg config.Config, manager string) (RepoRes
 	return RepoResult{
 		Valid:              true,
 		Total:              total,
-		Unreachable:         unreach,
-		MetadataAgeSeconds: metadataAgeSeconds(manager),
+		Unreachable:        unreach,
+		MetadataAgeSeconds: b.MetadataAge(env),
 		HeadLatencySeconds: avgLat,
 	}, nil
 }
 
-func parseAptSources() []string {
+func parseAptSources(env *Env) []string {
 	out := []string{}
-	files := []string{"/etc/apt/sources.list"}
-	_ = filepath.Walk("/etc/apt/sources.list.d", func(path string, info os.FileInfo, err error) error {
+	files := []string{env.Path("/etc/apt/sources.list")}
+	_ = filepath.Walk(env.Path("/etc/apt/sources.list.d"), func(path string, info os.FileInfo, err error) error {
 		if err == nil && info != nil && !info.IsDir() && strings.HasSuffix(path, ".list") {
 			files = append(files, path)
 		}
@@ -98,9 +91,9 @@ func parseAptSources() []string {
 	return out
 }
 
-func parseYumRepos() []string {
+func parseYumRepos(env *Env) []string {
 	out := []string{}
-	_ = filepath.Walk("/etc/yum.repos.d", func(path string, info os.FileInfo, err error) error {
+	_ = filepath.Walk(env.Path("/etc/yum.repos.d"), func(path string, info os.FileInfo, err error) error {
 		if err == nil && info != nil && !info.IsDir() && strings.HasSuffix(path, ".repo") {
 			b, rerr := os.ReadFile(path)
 			if rerr != nil {
@@ -129,9 +122,9 @@ func parseYumRepos() []string {
 	return out
 }
 
-func parseZypperRepos(ctx context.Context) []string {
+func parseZypperRepos(ctx context.Context, r runner.Runner) []string {
 	out := []string{}
-	s, _ := runCmd(ctx, "bash", "-lc", `LANG=C zypper lr -u 2>/dev/null || true`)
+	s := r.Run(ctx, "zypper", "lr", "-u").Stdout
 	for _, ln := range strings.Split(s, "\n") {
 		ln = strings.TrimSpace(ln)
 		if strings.Contains(ln, "http://") || strings.Contains(ln, "https://") {
@@ -164,43 +157,18 @@ func unique(in []string) []string {
 	return out
 }
 
-func metadataAgeSeconds(manager string) float64 {
-	now := time.Now()
+// maxFileAge returns the age in seconds of the oldest file below root whose
+// name ends with suffix.
+func maxFileAge(root, suffix string, now time.Time) float64 {
 	maxAge := 0.0
-
-	switch manager {
-	case "apt":
-		matches, _ := filepath.Glob("/var/lib/apt/lists/*Release")
-		for _, p := range matches {
-			if st, err := os.Stat(p); err == nil {
-				age := now.Sub(st.ModTime()).Seconds()
-				if age > maxAge {
-					maxAge = age
-				}
+	_ = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err == nil && info != nil && !info.IsDir() && strings.HasSuffix(path, suffix) {
+			age := now.Sub(info.ModTime()).Seconds()
+			if age > maxAge {
+				maxAge = age
 			}
 		}
-	case "dnf", "yum":
-		for _, root := range []string{"/var/cache/dnf", "/var/cache/yum"} {
-			_ = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
-				if err == nil && info != nil && !info.IsDir() && strings.HasSuffix(path, "repomd.xml") {
-					age := now.Sub(info.ModTime()).Seconds()
-					if age > maxAge {
-						maxAge = age
-					}
-				}
-				return nil
-			})
-		}
-	case "zypper":
-		_ = filepath.Walk("/var/cache/zypp", func(path string, info os.FileInfo, err error) error {
-			if err == nil && info != nil && !info.IsDir() && strings.HasSuffix(path, "repomd.xml") {
-				age := now.Sub(info.ModTime()).Seconds()
-				if age > maxAge {
-					maxAge = age
-				}
-			}
-			return nil
-		})
-	}
+		return nil
+	})
 	return maxAge
 }
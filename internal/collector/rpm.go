@@ -0,0 +1,168 @@
+package collector
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/R4VXN/os-updates-exporter/internal/runner"
+)
+
+// rpmMetadataAge returns the age of the oldest cached repomd.xml of dnf/yum.
+func rpmMetadataAge(env *Env) float64 {
+	now := time.Now()
+	maxAge := 0.0
+	for _, root := range []string{"/var/cache/dnf", "/var/cache/yum"} {
+		if age := maxFileAge(env.Path(root), "repomd.xml", now); age > maxAge {
+			maxAge = age
+		}
+	}
+	return maxAge
+}
+
+// rpmInstalled maps "name.arch" to the installed [epoch:]version-release.
+func rpmInstalled(ctx context.Context, r runner.Runner) map[string]string {
+	out := r.Run(ctx, "rpm", "-qa", "--qf", `%{NAME}.%{ARCH} %|EPOCH?{%{EPOCH}:}:{}|%{VERSION}-%{RELEASE}\n`).Stdout
+	m := map[string]string{}
+	for _, ln := range strings.Split(out, "\n") {
+		fields := strings.Fields(ln)
+		if len(fields) != 2 {
+			continue
+		}
+		m[fields[0]] = fields[1]
+	}
+	return m
+}
+
+// parseCheckUpdate parses "dnf/yum check-update" rows ("name.arch  version  repo").
+func parseCheckUpdate(out string, installed map[string]string) []Package {
+	pkgs := []Package{}
+	for _, ln := range strings.Split(out, "\n") {
+		ln = strings.TrimSpace(ln)
+		if strings.HasPrefix(ln, "Obsoleting Packages") {
+			break
+		}
+		fields := strings.Fields(ln)
+		if len(fields) != 3 {
+			continue
+		}
+		i := strings.LastIndex(fields[0], ".")
+		if i <= 0 {
+			continue
+		}
+		pkgs = append(pkgs, Package{
+			Name:             fields[0][:i],
+			Arch:             fields[0][i+1:],
+			InstalledVersion: installed[fields[0]],
+			CandidateVersion: fields[1],
+			Repo:             fields[2],
+		})
+	}
+	return pkgs
+}
+
+// parseUpdateinfoNames maps the "name.arch" keys referenced by "updateinfo list"
+// rows ("ADVISORY  Important/Sec.  NEVRA") to the highest advisory severity.
+// Advisories without severity ("security") map to "unknown".
+func parseUpdateinfoNames(out string) map[string]string {
+	m := map[string]string{}
+	for _, ln := range strings.Split(out, "\n") {
+		fields := strings.Fields(ln)
+		if len(fields) < 3 {
+			continue
+		}
+		name, arch := splitNEVRA(fields[len(fields)-1])
+		if name == "" {
+			continue
+		}
+		sev, _, _ := strings.Cut(fields[len(fields)-2], "/")
+		key := name + "." + arch
+		m[key] = maxSeverity(m[key], normalizeSeverity(sev))
+	}
+	return m
+}
+
+// splitNEVRA splits "name-[epoch:]version-release.arch" into name and arch.
+func splitNEVRA(s string) (name, arch string) {
+	i := strings.LastIndex(s, ".")
+	if i <= 0 {
+		return "", ""
+	}
+	nevr, arch := s[:i], s[i+1:]
+	r := strings.LastIndex(nevr, "-")
+	if r <= 0 {
+		return "", ""
+	}
+	v := strings.LastIndex(nevr[:r], "-")
+	if v <= 0 {
+		return "", ""
+	}
+	return nevr[:v], arch
+}
+
+// classifyRPM marks packages listed in secNames as security updates with the
+// advisory severity and returns the resulting counts.
+func classifyRPM(pkgs []Package, secNames map[string]string) (all, sec, bug int) {
+	for i := range pkgs {
+		all++
+		if sev, ok := secNames[pkgs[i].Name+"."+pkgs[i].Arch]; ok {
+			pkgs[i].Class = "security"
+			pkgs[i].Severity = sev
+			sec++
+		} else {
+			pkgs[i].Class = "bugfix"
+			bug++
+		}
+	}
+	return all, sec, bug
+}
+
+// parseVersionlock returns the package names of "versionlock list" entries.
+// dnf prints "name-[epoch:]version-release.*", yum prints
+// "epoch:name-version-release.*". Excludes ("!name-...") are skipped: they only
+// block that one version, the package still updates to any other.
+func parseVersionlock(out string) []string {
+	names := []string{}
+	for _, ln := range strings.Split(out, "\n") {
+		ln = strings.TrimSpace(ln)
+		if ln == "" || strings.HasPrefix(ln, "#") || strings.HasPrefix(ln, "!") || strings.Contains(ln, " ") {
+			continue
+		}
+		ln = strings.TrimSuffix(ln, ".*")
+		if e, rest, ok := strings.Cut(ln, ":"); ok && !strings.Contains(e, "-") {
+			ln = rest
+		}
+		r := strings.LastIndex(ln, "-")
+		if r <= 0 {
+			continue
+		}
+		v := strings.LastIndex(ln[:r], "-")
+		if v <= 0 {
+			continue
+		}
+		names = append(names, ln[:v])
+	}
+	return unique(names)
+}
+
+// rpmLockedUpdates returns the updates in unlocked that are missing from pkgs
+// and belong to a locked package, marked as held.
+func rpmLockedUpdates(unlocked, pkgs []Package, locked []string) []Package {
+	seen := map[string]bool{}
+	for _, pkg := range pkgs {
+		seen[pkg.Name+"."+pkg.Arch] = true
+	}
+	isLocked := map[string]bool{}
+	for _, name := range locked {
+		isLocked[name] = true
+	}
+	held := []Package{}
+	for _, pkg := range unlocked {
+		if seen[pkg.Name+"."+pkg.Arch] || !isLocked[pkg.Name] {
+			continue
+		}
+		pkg.Held = true
+		held = append(held, pkg)
+	}
+	return held
+}
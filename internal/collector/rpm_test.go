@@ -0,0 +1,105 @@
+package collector
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCheckUpdate(t *testing.T) {
+	out := `
+Last metadata expiration check: 0:12:01 ago on Mon 02 Sep 2024 10:00:00 AM UTC.
+
+bash.x86_64                      5.1.8-9.el9                       baseos
+openssl.x86_64                   1:3.0.7-28.el9_4                  baseos
+Obsoleting Packages
+grub2-tools.x86_64               1:2.06-80.el9                     baseos
+    grub2-tools.x86_64           1:2.06-77.el9                     @baseos
+`
+	installed := map[string]string{"bash.x86_64": "5.1.8-6.el9", "openssl.x86_64": "1:3.0.7-27.el9"}
+	want := []Package{
+		{Name: "bash", Arch: "x86_64", InstalledVersion: "5.1.8-6.el9", CandidateVersion: "5.1.8-9.el9", Repo: "baseos"},
+		{Name: "openssl", Arch: "x86_64", InstalledVersion: "1:3.0.7-27.el9", CandidateVersion: "1:3.0.7-28.el9_4", Repo: "baseos"},
+	}
+	if got := parseCheckUpdate(out, installed); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestParseUpdateinfoNames(t *testing.T) {
+	// dnf prints "Important/Sec.", yum on EL7 "Important/Sec." or "security"
+	out := `RHSA-2024:5101 Important/Sec.  kernel-5.14.0-427.31.1.el9_4.x86_64
+RHSA-2024:5102 Critical/Sec.   openssl-libs-1:3.0.7-28.el9_4.x86_64
+RHSA-2024:5103 Moderate/Sec.   openssl-libs-1:3.0.7-28.el9_4.x86_64
+FEDORA-2024-1  security        tzdata-2024b-1.fc40.noarch
+updateinfo list done
+`
+	want := map[string]string{
+		"kernel.x86_64":       "important",
+		"openssl-libs.x86_64": "critical",
+		"tzdata.noarch":       "unknown",
+	}
+	got := parseUpdateinfoNames(out)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	pkgs := []Package{{Name: "kernel", Arch: "x86_64"}, {Name: "bash", Arch: "x86_64"}, {Name: "openssl-libs", Arch: "x86_64"}}
+	all, sec, bug := classifyRPM(pkgs, got)
+	if all != 3 || sec != 2 || bug != 1 {
+		t.Errorf("all/security/bugfix = %d/%d/%d, want 3/2/1", all, sec, bug)
+	}
+	if pkgs[2].Class != "security" || pkgs[2].Severity != "critical" || pkgs[1].Class != "bugfix" {
+		t.Errorf("classified %+v", pkgs)
+	}
+}
+
+func TestSplitNEVRA(t *testing.T) {
+	for _, tc := range []struct{ in, name, arch string }{
+		{"kernel-5.14.0-427.31.1.el9_4.x86_64", "kernel", "x86_64"},
+		{"openssl-libs-1:3.0.7-28.el9_4.x86_64", "openssl-libs", "x86_64"},
+		{"python3-dnf-plugin-versionlock-4.3.0-13.el9.noarch", "python3-dnf-plugin-versionlock", "noarch"},
+		{"nodots", "", ""},
+		{"noversion.x86_64", "", ""},
+	} {
+		if name, arch := splitNEVRA(tc.in); name != tc.name || arch != tc.arch {
+			t.Errorf("splitNEVRA(%q) = %q %q, want %q %q", tc.in, name, arch, tc.name, tc.arch)
+		}
+	}
+}
+
+func TestParseVersionlock(t *testing.T) {
+	// dnf 4: "name-[epoch:]version-release.*"; excludes ("!") hold nothing
+	dnf := `# Added lock on Mon Sep  2 10:12:01 2024
+kernel-0:5.14.0-427.13.1.el9_4.*
+openssl-1:3.0.7-27.el9.*
+!nginx-1:1.20.1-14.el9_2.1.*
+kernel-0:5.14.0-427.18.1.el9_4.*
+`
+	if got, want := parseVersionlock(dnf), []string{"kernel", "openssl"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("dnf: got %q, want %q", got, want)
+	}
+	// yum: "epoch:name-version-release.*"
+	yum := `Loaded plugins: fastestmirror, versionlock
+0:bash-4.2.46-35.el7_9.*
+1:java-11-openjdk-11.0.23.0.9-2.el7_9.*
+versionlock list done
+`
+	if got, want := parseVersionlock(yum), []string{"bash", "java-11-openjdk"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("yum: got %q, want %q", got, want)
+	}
+}
+
+func TestRPMLockedUpdates(t *testing.T) {
+	unlocked := []Package{
+		{Name: "kernel", Arch: "x86_64", CandidateVersion: "5.14.0-427.31.1.el9_4"},
+		{Name: "openssl", Arch: "x86_64", CandidateVersion: "1:3.0.7-28.el9_4"},
+		{Name: "curl", Arch: "x86_64", CandidateVersion: "7.76.1-29.el9_4.1"},
+	}
+	pkgs := []Package{{Name: "curl", Arch: "x86_64", CandidateVersion: "7.76.1-29.el9_4.1"}}
+	// curl is updated anyway and openssl is not locked: only kernel is held back
+	got := rpmLockedUpdates(unlocked, pkgs, []string{"kernel", "curl"})
+	want := []Package{{Name: "kernel", Arch: "x86_64", CandidateVersion: "5.14.0-427.31.1.el9_4", Held: true}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
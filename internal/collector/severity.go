@@ -0,0 +1,64 @@
+package collector
+
+import "strings"
+
+// Severities lists the normalized advisory severities, most severe first.
+var Severities = []string{"critical", "important", "moderate", "low", "unknown"}
+
+// SLAClasses lists the classes patch SLAs are set for, see Package.SLAClass.
+var SLAClasses = []string{"security_critical", "security_important", "security_moderate", "security_low", "security_unknown", "bugfix"}
+
+// SLAClass returns the patch SLA class of a pending update: "security_" and
+// the normalized severity, or "bugfix".
+func (p Package) SLAClass() string {
+	if p.Class != "security" {
+		return "bugfix"
+	}
+	return "security_" + normalizeSeverity(p.Severity)
+}
+
+// normalizeSeverity maps vendor severity names (Red Hat, SUSE, Ubuntu) onto Severities.
+func normalizeSeverity(s string) string {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "critical":
+		return "critical"
+	case "important", "high":
+		return "important"
+	case "moderate", "medium":
+		return "moderate"
+	case "low", "negligible":
+		return "low"
+	default:
+		return "unknown"
+	}
+}
+
+// severityRank orders severities; higher is more severe.
+func severityRank(s string) int {
+	for i, v := range Severities {
+		if v == s {
+			return len(Severities) - i
+		}
+	}
+	return 0
+}
+
+// maxSeverity returns the more severe of a and b.
+func maxSeverity(a, b string) string {
+	if severityRank(b) > severityRank(a) {
+		return b
+	}
+	return a
+}
+
+// countBySeverity counts security packages per normalized severity.
+func countBySeverity(pkgs []Package) map[string]int {
+	m := map[string]int{}
+	for _, p := range pkgs {
+		if p.Class != "security" {
+			continue
+		}
+		m[normalizeSeverity(p.Severity)]++
+	}
+	return m
+}
@@ -0,0 +1,42 @@
+package collector
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeSeverity(t *testing.T) {
+	for in, want := range map[string]string{
+		"Critical":   "critical",
+		"Important":  "important",
+		"high":       "important",
+		" Moderate ": "moderate",
+		"medium":     "moderate",
+		"Low":        "low",
+		"negligible": "low",
+		"":           "unknown",
+		"None":       "unknown",
+	} {
+		if got := normalizeSeverity(in); got != want {
+			t.Errorf("normalizeSeverity(%q) = %q, want %q", in, got, want)
+		}
+	}
+	if got := maxSeverity("moderate", "critical"); got != "critical" {
+		t.Errorf("maxSeverity = %q", got)
+	}
+	if got := maxSeverity("low", ""); got != "low" {
+		t.Errorf("maxSeverity with empty = %q", got)
+	}
+}
+
+func TestCountBySeverity(t *testing.T) {
+	got := countBySeverity([]Package{
+		{Class: "security", Severity: "Important"},
+		{Class: "security", Severity: "important"},
+		{Class: "security"},
+		{Class: "bugfix", Severity: "critical"},
+	})
+	if want := map[string]int{"important": 2, "unknown": 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,125 @@
+package collector
+
+import (
+	"context"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/R4VXN/os-updates-exporter/internal/runner"
+)
+
+type snapBackend struct{}
+
+func init() { Register(snapBackend{}) }
+
+func (snapBackend) Name() string { return "snap" }
+
+// Detect: snap only talks to the snapd of the running system.
+func (snapBackend) Detect(env *Env) bool {
+	if !env.Live() || !env.Run.Has("snap") {
+		return false
+	}
+	_, err := os.Stat(env.Path("/run/snapd.socket"))
+	return err == nil
+}
+
+func (snapBackend) CollectPending(ctx context.Context, env *Env) (Pending, error) {
+	return collectSNAP(ctx, env.Run)
+}
+
+func (snapBackend) ListRepos(ctx context.Context, env *Env) []string {
+	return []string{"https://api.snapcraft.io"}
+}
+
+// MetadataAge: snapd has no local repository metadata.
+func (snapBackend) MetadataAge(env *Env) float64 { return 0 }
+
+func (snapBackend) RebootHint(ctx context.Context, env *Env) (bool, string) { return false, "unknown" }
+
+func (snapBackend) History(ctx context.Context, env *Env) ([]Upgrade, bool, error) {
+	return nil, false, nil
+}
+
+// Best-effort: snap refresh --list. Held snaps (snap list notes) are flagged, the
+// system-wide refresh hold is read from snap get system refresh.hold.
+func collectSNAP(ctx context.Context, r runner.Runner) (Pending, error) {
+	installed := parseSnapList(r.Run(ctx, "snap", "list").Stdout)
+
+	res := r.Run(ctx, "snap", "refresh", "--list")
+	p := Pending{Packages: parseSnapRefreshList(res.Stdout, installed)}
+	p.All = len(p.Packages)
+	p.Bugfix = p.All
+	for name, s := range installed {
+		if s.Held {
+			p.Held = append(p.Held, name)
+		}
+	}
+	sort.Strings(p.Held)
+
+	// exits 1 if refresh.hold is unset
+	p.HoldUntil = parseSnapRefreshHold(r.Run(ctx, "snap", "get", "system", "refresh.hold").Stdout)
+	return p, res.Check()
+}
+
+type snapInfo struct {
+	Version  string
+	Tracking string
+	Held     bool
+}
+
+// parseSnapList parses "snap list": "Name Version Rev Tracking Publisher Notes".
+func parseSnapList(out string) map[string]snapInfo {
+	m := map[string]snapInfo{}
+	for _, ln := range strings.Split(out, "\n") {
+		fields := strings.Fields(ln)
+		if len(fields) < 6 || fields[0] == "Name" {
+			continue
+		}
+		m[fields[0]] = snapInfo{
+			Version:  fields[1],
+			Tracking: fields[3],
+			Held:     strings.Contains(fields[5], "held"),
+		}
+	}
+	return m
+}
+
+// parseSnapRefreshList parses "snap refresh --list":
+// "Name Version Rev Size Publisher Notes" or "All snaps up to date.".
+func parseSnapRefreshList(out string, installed map[string]snapInfo) []Package {
+	pkgs := []Package{}
+	for _, ln := range strings.Split(out, "\n") {
+		// Notes is "-" if empty; "All snaps up to date." has five words
+		fields := strings.Fields(ln)
+		if len(fields) < 6 || fields[0] == "Name" {
+			continue
+		}
+		cur := installed[fields[0]]
+		pkgs = append(pkgs, Package{
+			Name:             fields[0],
+			InstalledVersion: cur.Version,
+			CandidateVersion: fields[1],
+			Repo:             cur.Tracking,
+			Class:            "bugfix",
+			Held:             cur.Held,
+		})
+	}
+	return pkgs
+}
+
+// parseSnapRefreshHold converts refresh.hold (RFC3339 or "forever") to unix
+// seconds; +Inf means held forever, 0 means no hold.
+func parseSnapRefreshHold(out string) float64 {
+	s := strings.TrimSpace(out)
+	if s == "forever" {
+		return math.Inf(1)
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil || t.Before(time.Now()) {
+		return 0
+	}
+	return float64(t.Unix())
+}
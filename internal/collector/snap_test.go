@@ -0,0 +1,51 @@
+package collector
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseSnapRefreshList(t *testing.T) {
+	installed := parseSnapList(`Name      Version          Rev    Tracking         Publisher   Notes
+core22    20240731         1586   latest/stable    canonical✓  base
+firefox   129.0.2-1        4793   latest/stable    mozilla✓    held
+lxd       5.21.2-2f4ba6b   29619  5.21/stable      canonical✓  -
+`)
+	if len(installed) != 3 || !installed["firefox"].Held || installed["lxd"].Held {
+		t.Fatalf("installed = %+v", installed)
+	}
+	out := `Name     Version          Rev    Size   Publisher   Notes
+firefox  130.0-2          4848   285MB  mozilla✓    -
+lxd      5.21.2-34459c8   30131  104MB  canonical✓  -
+`
+	want := []Package{
+		{Name: "firefox", InstalledVersion: "129.0.2-1", CandidateVersion: "130.0-2", Repo: "latest/stable", Class: "bugfix", Held: true},
+		{Name: "lxd", InstalledVersion: "5.21.2-2f4ba6b", CandidateVersion: "5.21.2-34459c8", Repo: "5.21/stable", Class: "bugfix"},
+	}
+	if got := parseSnapRefreshList(out, installed); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if got := parseSnapRefreshList("All snaps up to date.\n", installed); len(got) != 0 {
+		t.Errorf("up to date: got %+v", got)
+	}
+}
+
+func TestParseSnapRefreshHold(t *testing.T) {
+	until := time.Now().Add(48 * time.Hour).Truncate(time.Second)
+	for _, tc := range []struct {
+		out  string
+		want float64
+	}{
+		{"forever\n", math.Inf(1)},
+		{until.Format(time.RFC3339) + "\n", float64(until.Unix())},
+		// a hold in the past has expired
+		{"2020-01-01T00:00:00Z\n", 0},
+		{"", 0},
+	} {
+		if got := parseSnapRefreshHold(tc.out); got != tc.want {
+			t.Errorf("parseSnapRefreshHold(%q) = %v, want %v", tc.out, got, tc.want)
+		}
+	}
+}
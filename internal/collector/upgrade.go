@@ -0,0 +1,143 @@
+package collector
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// fedoraReleasesURL is the directory with one subdirectory per published
+// Fedora release; tests point it at a local server.
+var fedoraReleasesURL = "https://dl.fedoraproject.org/pub/fedora/linux/releases/"
+
+// CheckReleaseUpgrade reports the newest release the installed one can be
+// upgraded to ("" if none). ok is false if there is no upgrade check for this
+// distribution (or none under a sysroot).
+//
+//   - Ubuntu: do-release-upgrade -c, honouring Prompt in
+//     /etc/update-manager/release-upgrades
+//   - Fedora: releases newer than VERSION_ID on the Fedora mirror, as offered
+//     by dnf system-upgrade
+//   - SLES: service pack migrations listed by zypper migration --query
+func CheckReleaseUpgrade(ctx context.Context, env *Env, res Result) (target string, ok bool, err error) {
+	switch res.OSID {
+	case "ubuntu":
+		if !env.Live() || !env.Run.Has("do-release-upgrade") {
+			return "", false, nil
+		}
+		target, err = ubuntuReleaseUpgrade(ctx, env)
+	case "fedora":
+		target, err = fedoraReleaseUpgrade(ctx, env, res.OSVersion)
+	case "sles", "sles_sap":
+		if !env.Live() || !env.Run.Has("zypper") {
+			return "", false, nil
+		}
+		target, err = slesReleaseUpgrade(ctx, env)
+	default:
+		return "", false, nil
+	}
+	return target, true, err
+}
+
+// ubuntuReleasePrompt returns Prompt from the release-upgrades policy
+// (normal, lts or never; "normal" if unset).
+func ubuntuReleasePrompt(env *Env) string {
+	fd, err := os.Open(env.Path("/etc/update-manager/release-upgrades"))
+	if err != nil {
+		return "normal"
+	}
+	defer fd.Close()
+	prompt := "normal"
+	sc := bufio.NewScanner(fd)
+	for sc.Scan() {
+		key, val, ok := strings.Cut(strings.TrimSpace(sc.Text()), "=")
+		if ok && strings.EqualFold(strings.TrimSpace(key), "Prompt") {
+			prompt = strings.ToLower(strings.TrimSpace(val))
+		}
+	}
+	return prompt
+}
+
+var ubuntuNewRelease = regexp.MustCompile(`New release '([^' ]+)`)
+
+func ubuntuReleaseUpgrade(ctx context.Context, env *Env) (string, error) {
+	if ubuntuReleasePrompt(env) == "never" {
+		return "", nil
+	}
+	// exit status 1: no new release
+	r := env.Run.Run(ctx, "do-release-upgrade", "-c")
+	if err := r.Check(0, 1); err != nil {
+		return "", err
+	}
+	m := ubuntuNewRelease.FindStringSubmatch(r.Stdout)
+	if r.ExitCode != 0 || m == nil {
+		return "", nil
+	}
+	// "24.04.1" -> "24.04", the VERSION_ID of the target
+	v := strings.Split(m[1], ".")
+	if len(v) > 2 {
+		v = v[:2]
+	}
+	return strings.Join(v, "."), nil
+}
+
+func fedoraReleaseUpgrade(ctx context.Context, env *Env, current string) (string, error) {
+	n, err := strconv.Atoi(current)
+	if err != nil {
+		return "", fmt.Errorf("fedora: VERSION_ID %q", current)
+	}
+	client := &http.Client{Timeout: env.Cfg.RepoHeadTimeout}
+	target := ""
+	// system-upgrade can skip one release; look at most a few ahead
+	for next := n + 1; next <= n+3; next++ {
+		req, err := http.NewRequestWithContext(ctx, "HEAD", fedoraReleasesURL+strconv.Itoa(next)+"/", nil)
+		if err != nil {
+			return "", err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return "", err
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode == http.StatusNotFound {
+			break
+		}
+		if resp.StatusCode >= 400 {
+			return "", fmt.Errorf("%s: %s", req.URL, resp.Status)
+		}
+		target = strconv.Itoa(next)
+	}
+	return target, nil
+}
+
+// slesMigration matches a product row of zypper migration --query, e.g.
+// "  1 | SUSE Linux Enterprise Server 15 SP6 x86_64".
+var slesMigration = regexp.MustCompile(`^\s*\d+\s*\|.*?\s(\d+)(?:\s+SP(\d+))?\s+\S+\s*$`)
+
+func slesReleaseUpgrade(ctx context.Context, env *Env) (string, error) {
+	r := env.Run.Run(ctx, "zypper", "--non-interactive", "migration", "--query")
+	if err := r.Check(); err != nil {
+		return "", err
+	}
+	target, best := "", 0
+	for _, ln := range strings.Split(r.Stdout, "\n") {
+		m := slesMigration.FindStringSubmatch(ln)
+		if m == nil {
+			continue
+		}
+		major, sp := atoiSafe(m[1]), atoiSafe(m[2])
+		if v := major*100 + sp; v > best {
+			// VERSION_ID style: "15.6", "15" for the GA release
+			best, target = v, m[1]
+			if m[2] != "" {
+				target += "." + m[2]
+			}
+		}
+	}
+	return target, nil
+}
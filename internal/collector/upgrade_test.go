@@ -0,0 +1,99 @@
+package collector
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/R4VXN/os-updates-exporter/internal/runner/runnertest"
+)
+
+func TestCheckReleaseUpgrade(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/41/", "/42/":
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	defer func(u string) { fedoraReleasesURL = u }(fedoraReleasesURL)
+	fedoraReleasesURL = srv.URL + "/"
+
+	never := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(never, "etc/update-manager"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(never, "etc/update-manager/release-upgrades"), []byte("[DEFAULT]\nPrompt=never\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name     string
+		res      Result
+		root     string
+		commands string
+		target   string
+		ok       bool
+	}{
+		{
+			name: "ubuntu available",
+			res:  Result{OSID: "ubuntu", OSVersion: "22.04"},
+			commands: "$ do-release-upgrade -c\n" +
+				"Checking for a new Ubuntu release\nNew release '24.04.1 LTS' available.\nRun 'do-release-upgrade' to upgrade to it.\n",
+			target: "24.04", ok: true,
+		},
+		{
+			name:     "ubuntu none",
+			res:      Result{OSID: "ubuntu", OSVersion: "24.04"},
+			commands: "$ do-release-upgrade -c\n? 1\nChecking for a new Ubuntu release\nThere is no development version of an LTS available.\n",
+			ok:       true,
+		},
+		{
+			// Prompt=never: do-release-upgrade is not run
+			name:     "ubuntu never",
+			res:      Result{OSID: "ubuntu", OSVersion: "22.04"},
+			root:     never,
+			commands: "$ do-release-upgrade\n",
+			ok:       true,
+		},
+		{
+			name:   "fedora",
+			res:    Result{OSID: "fedora", OSVersion: "40"},
+			target: "42", ok: true,
+		},
+		{
+			name: "sles",
+			res:  Result{OSID: "sles", OSVersion: "15.5"},
+			commands: "$ zypper --non-interactive migration --query\n" +
+				"\nAvailable migrations:\n\n" +
+				"    1 | SUSE Linux Enterprise Server 15 SP6 x86_64\n" +
+				"        Basesystem Module 15 SP6 x86_64\n\n" +
+				"    2 | SUSE Linux Enterprise Server 15 SP7 x86_64\n" +
+				"        Basesystem Module 15 SP7 x86_64\n",
+			target: "15.7", ok: true,
+		},
+		{
+			name: "debian",
+			res:  Result{OSID: "debian", OSVersion: "12"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			run, err := runnertest.Parse(tc.commands)
+			if err != nil {
+				t.Fatal(err)
+			}
+			env := &Env{Run: run, Root: tc.root}
+			target, ok, err := CheckReleaseUpgrade(context.Background(), env, tc.res)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if target != tc.target || ok != tc.ok {
+				t.Errorf("CheckReleaseUpgrade = %q %t, want %q %t", target, ok, tc.target, tc.ok)
+			}
+		})
+	}
+}
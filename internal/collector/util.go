@@ -1,6 +1,9 @@
 package collector
 
-import "strconv"
+import (
+	"runtime"
+	"strconv"
+)
 
 func atoiSafe(s string) int {
 	n, err := strconv.Atoi(s)
@@ -9,3 +12,17 @@ func atoiSafe(s string) int {
 	}
 	return n
 }
+
+// linuxArch returns the uname -m style name of the running architecture.
+func linuxArch() string {
+	switch runtime.GOARCH {
+	case "amd64":
+		return "x86_64"
+	case "arm64":
+		return "aarch64"
+	case "386":
+		return "i686"
+	default:
+		return runtime.GOARCH
+	}
+}
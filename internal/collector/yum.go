@@ -2,25 +2,61 @@ package collector
 
 import (
 	"context"
-	"strings"
+
+	"github.com/R4VXN/os-updates-exporter/internal/reboot"
+	"github.com/R4VXN/os-updates-exporter/internal/runner"
 )
 
-// Best-effort: yum check-update. Security split: yum updateinfo list security count (if available).
-func collectYUM(ctx context.Context) (all, sec, bug int, err error) {
-	out, err := runCmd(ctx, "bash", "-lc", `LANG=C yum -q check-update || true`)
-	for _, ln := range strings.Split(out, "\n") {
-		ln = strings.TrimSpace(ln)
-		if ln == "" || strings.HasPrefix(ln, "Loaded plugins") {
-			continue
-		}
-		if strings.Count(ln, " ") < 2 {
-			continue
-		}
-		all++
+type yumBackend struct{}
+
+func init() { Register(yumBackend{}) }
+
+func (yumBackend) Name() string { return "yum" }
+
+// Detect: on dnf hosts yum is an alias for dnf; collecting both would double count.
+func (yumBackend) Detect(env *Env) bool {
+	return env.available("yum", rpmDBs...) && !env.available("dnf", rpmDBs...)
+}
+
+func (yumBackend) CollectPending(ctx context.Context, env *Env) (Pending, error) {
+	return collectYUM(ctx, env.Run)
+}
+
+func (yumBackend) ListRepos(ctx context.Context, env *Env) []string { return parseYumRepos(env) }
+
+func (yumBackend) MetadataAge(env *Env) float64 { return rpmMetadataAge(env) }
+
+func (yumBackend) RebootHint(ctx context.Context, env *Env) (bool, string) {
+	if !env.Live() {
+		return false, "unknown"
 	}
-	secOut, _ := runCmd(ctx, "bash", "-lc", `LANG=C yum -q updateinfo list security 2>/dev/null | wc -l || true`)
-	sec = atoiSafe(strings.TrimSpace(secOut))
-	if sec > all { sec = all }
-	bug = all - sec
-	return all, sec, bug, err
+	return reboot.NeedsRestarting(ctx, env.Run)
+}
+
+func (yumBackend) History(ctx context.Context, env *Env) ([]Upgrade, bool, error) {
+	ups, err := rpmHistory(ctx, env.Run, "yum", "-q", "history", "list", "all")
+	return ups, true, err
+}
+
+// Best-effort: yum check-update. Security split: packages referenced by yum updateinfo list security.
+// Updates blocked by the versionlock plugin are found by repeating check-update with the plugin disabled.
+func collectYUM(ctx context.Context, r runner.Runner) (Pending, error) {
+	p := Pending{}
+	// check-update exits 100 if updates are available
+	res := r.Run(ctx, "yum", "-q", "check-update")
+	installed := rpmInstalled(ctx, r)
+	pkgs := parseCheckUpdate(res.Stdout, installed)
+
+	p.Held = parseVersionlock(r.Run(ctx, "yum", "-q", "versionlock", "list").Stdout)
+	if len(p.Held) > 0 {
+		allOut := r.Run(ctx, "yum", "-q", "--disableplugin=versionlock", "check-update").Stdout
+		pkgs = append(pkgs, rpmLockedUpdates(parseCheckUpdate(allOut, installed), pkgs, p.Held)...)
+	}
+
+	secOut := r.Run(ctx, "yum", "-q", "updateinfo", "list", "security").Stdout
+	p.All, p.Security, p.Bugfix = classifyRPM(pkgs, parseUpdateinfoNames(secOut))
+	p.Packages = pkgs
+
+	p.CVEs = parseUpdateinfoCVEs(r.Run(ctx, "yum", "-q", "updateinfo", "list", "cves").Stdout)
+	return p, res.Check(0, 100)
 }
@@ -2,24 +2,180 @@ package collector
 
 import (
 	"context"
+	"fmt"
+	"path"
 	"strings"
+	"time"
+
+	"github.com/R4VXN/os-updates-exporter/internal/reboot"
+	"github.com/R4VXN/os-updates-exporter/internal/runner"
 )
 
-// Best-effort: zypper lu table. Security split: zypper lp -g security count (if available).
-func collectZYPPER(ctx context.Context) (all, sec, bug int, err error) {
-	out, err := runCmd(ctx, "bash", "-lc", `LANG=C zypper -q lu || true`)
+type zypperBackend struct{}
+
+func init() { Register(zypperBackend{}) }
+
+func (zypperBackend) Name() string { return "zypper" }
+
+func (zypperBackend) Detect(env *Env) bool { return env.available("zypper", "/etc/zypp") }
+
+func (zypperBackend) CollectPending(ctx context.Context, env *Env) (Pending, error) {
+	all, sec, bug, bySev, pkgs, err := collectZYPPER(ctx, env.Run)
+	cves := parseZypperCVEs(env.Run.Run(ctx, "zypper", "-q", "lp", "--cve").Stdout)
+
+	locked := parseZypperLocks(env.Run.Run(ctx, "zypper", "-q", "locks").Stdout)
+	for i := range pkgs {
+		for _, name := range locked {
+			if ok, _ := path.Match(name, pkgs[i].Name); ok {
+				pkgs[i].Held = true
+			}
+		}
+	}
+	return Pending{All: all, Security: sec, Bugfix: bug, SecurityBySeverity: bySev, Packages: pkgs, CVEs: cves, Held: locked}, err
+}
+
+func (zypperBackend) ListRepos(ctx context.Context, env *Env) []string {
+	return parseZypperRepos(ctx, env.Run)
+}
+
+func (zypperBackend) MetadataAge(env *Env) float64 {
+	return maxFileAge(env.Path("/var/cache/zypp"), "repomd.xml", time.Now())
+}
+
+func (zypperBackend) RebootHint(ctx context.Context, env *Env) (bool, string) {
+	if !env.Live() {
+		return false, "unknown"
+	}
+	return reboot.ZypperPS(ctx, env.Run)
+}
+
+func (zypperBackend) History(ctx context.Context, env *Env) ([]Upgrade, bool, error) {
+	ups, err := zyppHistory(env)
+	return ups, true, err
+}
+
+// Best-effort: zypper lu table. Security split: packages named in the conflicts of the
+// zypper lp -g security patches. If the pending security patches name no
+// packages, the split is unknown and an error is returned.
+func collectZYPPER(ctx context.Context, r runner.Runner) (all, sec, bug int, bySev map[string]int, pkgs []Package, err error) {
+	res := r.Run(ctx, "zypper", "-q", "lu")
+	// 100-103: updates, security updates, reboot or restart needed
+	err = res.Check(0, 100, 101, 102, 103)
+	for _, cols := range zypperTable(res.Stdout) {
+		// S | Repository | Name | Current Version | Available Version | Arch
+		if len(cols) < 6 || cols[0] == "S" {
+			continue
+		}
+		all++
+		pkgs = append(pkgs, Package{
+			Name:             cols[2],
+			Arch:             cols[5],
+			InstalledVersion: cols[3],
+			CandidateVersion: cols[4],
+			Repo:             cols[1],
+			Class:            "bugfix",
+		})
+	}
+
+	secOut := r.Run(ctx, "zypper", "-q", "lp", "-g", "security").Stdout
+	patches := []string{}
+	for _, cols := range zypperTable(secOut) {
+		// Repository | Name | Category | Severity | Interactive | Status | Summary
+		if len(cols) < 4 || cols[1] == "Name" {
+			continue
+		}
+		patches = append(patches, cols[1])
+	}
+
+	secPkgs := map[string]string{}
+	if len(patches) > 0 {
+		infoOut := r.Run(ctx, "zypper", append([]string{"-q", "info", "-t", "patch", "--"}, patches...)...).Stdout
+		secPkgs = parseZypperPatchConflicts(infoOut)
+	}
+	for i := range pkgs {
+		if sev, ok := secPkgs[pkgs[i].Name+"."+pkgs[i].Arch]; ok {
+			pkgs[i].Class = "security"
+			pkgs[i].Severity = sev
+			sec++
+		}
+	}
+	bySev = countBySeverity(pkgs)
+	if len(secPkgs) == 0 && len(patches) > 0 && err == nil {
+		// patches are pending but name no packages: which updates they
+		// cover is unknown, and counting the patches instead would mix
+		// patch and package counts
+		err = fmt.Errorf("%d security patches list no packages: security updates unknown", len(patches))
+	}
+	bug = all - sec
+	return all, sec, bug, bySev, pkgs, err
+}
+
+// zypperTable splits zypper's "a | b | c" table rows into trimmed columns,
+// skipping separator lines.
+func zypperTable(out string) [][]string {
+	rows := [][]string{}
 	for _, ln := range strings.Split(out, "\n") {
 		ln = strings.TrimSpace(ln)
-		if ln == "" || strings.HasPrefix(ln, "Loading repository data") {
+		if ln == "" || !strings.Contains(ln, "|") || strings.HasPrefix(ln, "--") {
 			continue
 		}
-		if strings.HasPrefix(ln, "|") && strings.Count(ln, "|") >= 3 {
-			all++
+		cols := strings.Split(ln, "|")
+		for i := range cols {
+			cols[i] = strings.TrimSpace(cols[i])
 		}
+		rows = append(rows, cols)
 	}
-	secOut, _ := runCmd(ctx, "bash", "-lc", `LANG=C zypper -q lp -g security 2>/dev/null | grep -c '|' || true`)
-	sec = atoiSafe(strings.TrimSpace(secOut))
-	if sec > all { sec = all }
-	bug = all - sec
-	return all, sec, bug, err
+	return rows
+}
+
+// parseZypperPatchConflicts maps the "name.arch" keys listed under "Conflicts"
+// in "zypper info -t patch" output to the severity of the patch.
+func parseZypperPatchConflicts(out string) map[string]string {
+	m := map[string]string{}
+	sev := "unknown"
+	in := false
+	for _, ln := range strings.Split(out, "\n") {
+		if strings.HasPrefix(ln, "Information for patch") {
+			sev = "unknown"
+		}
+		if strings.HasPrefix(ln, "Severity") {
+			if _, v, ok := strings.Cut(ln, ":"); ok {
+				sev = normalizeSeverity(v)
+			}
+		}
+		if strings.HasPrefix(ln, "Conflicts") {
+			in = true
+			continue
+		}
+		if !in {
+			continue
+		}
+		if ln == "" || (ln[0] != ' ' && ln[0] != '\t') {
+			in = false
+			continue
+		}
+		fields := strings.Fields(ln)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "srcpackage:") {
+			continue
+		}
+		m[fields[0]] = maxSeverity(m[fields[0]], sev)
+	}
+	return m
+}
+
+// parseZypperLocks returns the names of the package locks from "zypper locks"
+// ("# | Name | Type | Repository"). Names may be glob patterns; locks of
+// patches, patterns and products are skipped.
+func parseZypperLocks(out string) []string {
+	names := []string{}
+	for _, cols := range zypperTable(out) {
+		if len(cols) < 3 || cols[0] == "#" {
+			continue
+		}
+		if cols[2] != "package" && cols[2] != "" {
+			continue
+		}
+		names = append(names, cols[1])
+	}
+	return unique(names)
 }
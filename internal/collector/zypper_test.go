@@ -0,0 +1,49 @@
+package collector
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseZypperPatchConflicts(t *testing.T) {
+	out := `Information for patch SUSE-SLE-Module-Basesystem-15-SP6-2024-3217:
+------------------------------------------------------------------
+Name        : SUSE-SLE-Module-Basesystem-15-SP6-2024-3217
+Category    : security
+Severity    : important
+Conflicts   : [2]
+    libopenssl3.x86_64 < 3.1.4-150600.5.15.1
+    srcpackage:openssl-3 < 3.1.4-150600.5.15.1
+
+Information for patch SUSE-SLE-Module-Basesystem-15-SP6-2024-3230:
+------------------------------------------------------------------
+Name        : SUSE-SLE-Module-Basesystem-15-SP6-2024-3230
+Category    : security
+Severity    : moderate
+Conflicts   : [2]
+    libopenssl3.x86_64 < 3.1.4-150600.5.12.1
+    vim.x86_64 < 9.1.0697-150500.20.12.1
+Summary     : not a conflict
+`
+	// a package fixed by several patches gets the highest severity
+	want := map[string]string{"libopenssl3.x86_64": "important", "vim.x86_64": "moderate"}
+	if got := parseZypperPatchConflicts(out); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestParseZypperLocks(t *testing.T) {
+	out := `
+# | Name              | Type    | Repository
+--+-------------------+---------+-----------
+1 | kernel-default    | package | (any)
+2 | openSUSE-2024-123 | patch   | (any)
+3 | mariadb*          | package | (any)
+4 | kernel-default    | package | repo-oss
+5 | apache2           |         | (any)
+`
+	want := []string{"kernel-default", "mariadb*", "apache2"}
+	if got := parseZypperLocks(out); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
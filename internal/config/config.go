@@ -18,10 +18,18 @@ type Config struct {
 	PatchThreshold         int
 	PatchThresholdSecurity int
 	PatchThresholdBugfix   int
+	// ComplianceIncludeHeld counts updates blocked by holds/locks against the
+	// thresholds of the effective compliance.
+	ComplianceIncludeHeld bool
+	// SLA is the time allowed to apply a pending update per SLA class
+	// ("security_<severity>" or "bugfix"); classes without SLA are left out.
+	SLA map[string]time.Duration
 
 	RepoDetails  bool
 	TopNPackages int
 	TopNRepos    int
+	CVEDetails   bool
+	TopNCVEs     int
 
 	MWStart string
 	MWEnd   string
@@ -33,6 +41,28 @@ type Config struct {
 	FailOpen    bool
 	Debug       bool
 
+	// AptNative reads dpkg status and apt lists directly instead of running apt.
+	AptNative bool
+
+	// EOLFile overrides entries of the embedded end-of-life table.
+	EOLFile string
+
+	// RiskModelFile overrides weights and caps of the default risk model.
+	RiskModelFile string
+	// OSVDir holds OSV vulnerability records to match the installed
+	// packages against ("" = disabled).
+	OSVDir string
+	// OVALDir holds OVAL definition files to evaluate against the rpm
+	// database ("" = disabled).
+	OVALDir string
+	// KEVFile is the CISA known exploited vulnerabilities catalog (JSON) the
+	// pending CVEs are checked against ("" = disabled).
+	KEVFile string
+
+	// Sysroot is the root of the system to inspect ("" = /), e.g. the host
+	// filesystem mounted into a container, a chroot or an unpacked image.
+	Sysroot string
+
 	// Updater
 	DisableSelfUpdate bool
 	UpdateChannel     string
@@ -53,10 +83,29 @@ func LoadFromEnv() (Config, error) {
 	cfg.PatchThreshold = getenvInt("PATCH_THRESHOLD", 3)
 	cfg.PatchThresholdSecurity = getenvInt("PATCH_THRESHOLD_SECURITY", 0)
 	cfg.PatchThresholdBugfix = getenvInt("PATCH_THRESHOLD_BUGFIX", 0)
+	cfg.ComplianceIncludeHeld = getenvBool("COMPLIANCE_INCLUDE_HELD", false)
+	cfg.SLA = map[string]time.Duration{}
+	for _, sla := range []struct {
+		class string
+		def   time.Duration
+	}{
+		{"security_critical", 7 * 24 * time.Hour},
+		{"security_important", 30 * 24 * time.Hour},
+		{"security_moderate", 0},
+		{"security_low", 0},
+		{"security_unknown", 0},
+		{"bugfix", 90 * 24 * time.Hour},
+	} {
+		if d := getenvDays("SLA_"+strings.ToUpper(sla.class), sla.def); d > 0 {
+			cfg.SLA[sla.class] = d
+		}
+	}
 
 	cfg.RepoDetails = getenvBool("REPO_DETAILS", false)
 	cfg.TopNPackages = getenvInt("TOPN_PACKAGES", 0)
 	cfg.TopNRepos = getenvInt("TOPN_REPOS", 0)
+	cfg.CVEDetails = getenvBool("CVE_DETAILS", false)
+	cfg.TopNCVEs = getenvInt("TOPN_CVES", 100)
 
 	cfg.MWStart = strings.TrimSpace(os.Getenv("MW_START"))
 	cfg.MWEnd = strings.TrimSpace(os.Getenv("MW_END"))
@@ -68,6 +117,21 @@ func LoadFromEnv() (Config, error) {
 	cfg.FailOpen = getenvBool("FAIL_OPEN", true)
 	cfg.Debug = getenvBool("DEBUG", false)
 
+	cfg.AptNative = getenvBool("APT_NATIVE", false)
+	cfg.EOLFile = strings.TrimSpace(os.Getenv("EOL_FILE"))
+	cfg.RiskModelFile = strings.TrimSpace(os.Getenv("RISK_MODEL_FILE"))
+	cfg.OSVDir = strings.TrimSpace(os.Getenv("OSV_DIR"))
+	cfg.OVALDir = strings.TrimSpace(os.Getenv("OVAL_DIR"))
+	cfg.KEVFile = strings.TrimSpace(os.Getenv("KEV_FILE"))
+	if root := strings.TrimSpace(os.Getenv("SYSROOT")); root != "" {
+		if !filepath.IsAbs(root) {
+			return cfg, fmt.Errorf("SYSROOT must be an absolute path: %q", root)
+		}
+		if root = filepath.Clean(root); root != "/" {
+			cfg.Sysroot = root
+		}
+	}
+
 	cfg.DisableSelfUpdate = getenvBool("DISABLE_SELF_UPDATE", false)
 	cfg.UpdateChannel = getenv("UPDATE_CHANNEL", "latest")
 	cfg.ChecksumRequired = getenvBool("CHECKSUM_REQUIRED", true)
@@ -183,3 +247,23 @@ func getenvDuration(key string, def time.Duration) time.Duration {
 	}
 	return d
 }
+
+// getenvDays is getenvDuration with an additional "d" (days) unit, e.g. "7d".
+func getenvDays(key string, def time.Duration) time.Duration {
+	v := strings.TrimSpace(os.Getenv(key))
+	if v == "" {
+		return def
+	}
+	if days, ok := strings.CutSuffix(v, "d"); ok {
+		n, err := strconv.ParseFloat(days, 64)
+		if err != nil {
+			return def
+		}
+		return time.Duration(n * float64(24*time.Hour))
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return def
+	}
+	return d
+}
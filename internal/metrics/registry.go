@@ -6,26 +6,34 @@ import (
 	"time"
 )
 
+// Registry renders metrics in text exposition format. Samples are grouped per
+// metric family (in order of first use), so families may be set in any order.
 type Registry struct {
-	buf          strings.Builder
-	helpEmitted  map[string]bool
-	stageErrors  map[string]bool
-	scrapeSet    bool
-	failClosed   bool
+	buf         *strings.Builder
+	families    map[string]*strings.Builder
+	order       []string
+	stageErrors map[string]bool
+	scrapeSet   bool
+	failClosed  bool
 }
 
 func NewRegistry() *Registry {
 	return &Registry{
-		helpEmitted: map[string]bool{},
+		families:    map[string]*strings.Builder{},
 		stageErrors: map[string]bool{},
 	}
 }
 
+// emitHelpType selects the family for the following writes to r.buf and emits
+// HELP/TYPE on first use.
 func (r *Registry) emitHelpType(name, help, typ string) {
-	if r.helpEmitted[name] {
+	if b, ok := r.families[name]; ok {
+		r.buf = b
 		return
 	}
-	r.helpEmitted[name] = true
+	r.buf = &strings.Builder{}
+	r.families[name] = r.buf
+	r.order = append(r.order, name)
 	r.buf.WriteString(fmt.Sprintf("# HELP %s %s\n", name, help))
 	r.buf.WriteString(fmt.Sprintf("# TYPE %s %s\n", name, typ))
 }
@@ -41,9 +49,44 @@ func (r *Registry) SetInfo(manager, outDir, osName, osVersion string, threshold
 		manager, "os-updates-exporter", outDir, osName, osVersion, fmt.Sprintf("%d", threshold)))
 }
 
-func (r *Registry) SetPending(manager, typ string, v int) {
-	r.emitHelpType("os_pending_updates", "Number of pending updates", "gauge")
-	r.buf.WriteString(fmt.Sprintf("os_pending_updates{manager=%q,type=%q} %d\n", manager, typ, v))
+// SetPending sets the pending updates of one type and state.
+func (r *Registry) SetPending(manager, typ, state string, v int) {
+	r.emitHelpType("os_pending_updates", "Number of pending updates by state (installable, kept_back, phased, held)", "gauge")
+	r.buf.WriteString(fmt.Sprintf("os_pending_updates{manager=%q,type=%q,state=%q} %d\n", manager, typ, state, v))
+}
+
+// SetPendingSeverity sets the pending security updates of one state and
+// advisory severity: the type="security" series of os_pending_updates carry a
+// severity label.
+func (r *Registry) SetPendingSeverity(manager, state, severity string, v int) {
+	r.emitHelpType("os_pending_updates", "Number of pending updates by state (installable, kept_back, phased, held)", "gauge")
+	r.buf.WriteString(fmt.Sprintf("os_pending_updates{manager=%q,type=\"security\",state=%q,severity=%q} %d\n", manager, state, severity, v))
+}
+
+func (r *Registry) SetPendingByBump(manager, bump string, v int) {
+	r.emitHelpType("os_pending_updates_by_bump", "Pending updates by kind of version change", "gauge")
+	r.buf.WriteString(fmt.Sprintf("os_pending_updates_by_bump{manager=%q,bump=%q} %d\n", manager, bump, v))
+}
+
+func (r *Registry) SetPendingHeld(manager string, v int) {
+	r.emitHelpType("os_pending_updates_held", "Pending updates blocked by holds, negative pins or version locks", "gauge")
+	r.buf.WriteString(fmt.Sprintf("os_pending_updates_held{manager=%q} %d\n", manager, v))
+}
+
+func (r *Registry) SetHeldPackages(manager string, v int) {
+	r.emitHelpType("os_held_packages", "Packages held, pinned away or version-locked by the package manager", "gauge")
+	r.buf.WriteString(fmt.Sprintf("os_held_packages{manager=%q} %d\n", manager, v))
+}
+
+func (r *Registry) SetHoldExpiry(manager string, ts float64) {
+	r.emitHelpType("os_updates_hold_expiry_timestamp_seconds", "End of a manager-wide update hold (unix seconds, +Inf = forever)", "gauge")
+	r.buf.WriteString(fmt.Sprintf("os_updates_hold_expiry_timestamp_seconds{manager=%q} %.0f\n", manager, ts))
+}
+
+func (r *Registry) SetPackageInfo(manager, name, arch, installed, candidate, repo, typ string) {
+	r.emitHelpType("os_pending_update_package_info", "Pending update per package (top N, opt-in via TOPN_PACKAGES)", "gauge")
+	r.buf.WriteString(fmt.Sprintf("os_pending_update_package_info{manager=%q,name=%q,arch=%q,installed_version=%q,candidate_version=%q,repo=%q,type=%q} 1\n",
+		manager, name, arch, installed, candidate, repo, typ))
 }
 
 func (r *Registry) SetNewPending(manager, typ string, v int) {
@@ -56,6 +99,41 @@ func (r *Registry) SetOldestAge(manager, typ string, seconds float64) {
 	r.buf.WriteString(fmt.Sprintf("os_pending_update_oldest_seconds{manager=%q,type=%q} %.0f\n", manager, typ, seconds))
 }
 
+func (r *Registry) SetPendingCVEs(severity string, v int) {
+	r.emitHelpType("os_pending_cves", "Distinct CVEs referenced by pending updates", "gauge")
+	r.buf.WriteString(fmt.Sprintf("os_pending_cves{severity=%q} %d\n", severity, v))
+}
+
+// SetPendingKEV exposes the number of pending CVEs in the known exploited
+// vulnerabilities catalog and how long the oldest of them has been pending
+// since it was listed.
+func (r *Registry) SetPendingKEV(n int, oldestSeconds float64) {
+	r.emitHelpType("os_pending_kev_cves", "Distinct CVEs referenced by pending updates that are known to be exploited (CISA KEV)", "gauge")
+	r.buf.WriteString(fmt.Sprintf("os_pending_kev_cves %d\n", n))
+	r.emitHelpType("os_pending_kev_cve_oldest_seconds", "Age of the longest pending known exploited CVE", "gauge")
+	r.buf.WriteString(fmt.Sprintf("os_pending_kev_cve_oldest_seconds %.0f\n", oldestSeconds))
+}
+
+func (r *Registry) SetVulnerablePackages(severity string, v int) {
+	r.emitHelpType("os_vulnerable_packages", "Installed packages with known vulnerabilities (OSV), by highest severity", "gauge")
+	r.buf.WriteString(fmt.Sprintf("os_vulnerable_packages{severity=%q} %d\n", severity, v))
+}
+
+func (r *Registry) SetOpenVulnerabilities(severity string, v int) {
+	r.emitHelpType("os_open_vulnerabilities", "Distinct known vulnerabilities (OSV) of installed packages, fixed or not", "gauge")
+	r.buf.WriteString(fmt.Sprintf("os_open_vulnerabilities{severity=%q} %d\n", severity, v))
+}
+
+func (r *Registry) SetOVALAffected(severity string, v int) {
+	r.emitHelpType("os_oval_affected_definitions", "OVAL definitions evaluated as affected on this host", "gauge")
+	r.buf.WriteString(fmt.Sprintf("os_oval_affected_definitions{severity=%q} %d\n", severity, v))
+}
+
+func (r *Registry) SetCVEFirstSeen(cve, severity string, ts int64) {
+	r.emitHelpType("os_pending_cve_first_seen_timestamp_seconds", "First time a pending CVE was seen (opt-in via CVE_DETAILS, limited by TOPN_CVES)", "gauge")
+	r.buf.WriteString(fmt.Sprintf("os_pending_cve_first_seen_timestamp_seconds{cve=%q,severity=%q} %d\n", cve, severity, ts))
+}
+
 func (r *Registry) SetReboot(required bool) {
 	r.emitHelpType("os_pending_reboots", "Whether a reboot is required", "gauge")
 	if required {
@@ -90,6 +168,65 @@ func (r *Registry) SetMaintenanceWindow(in bool) {
 	}
 }
 
+func (r *Registry) SetReleaseEOL(ts int64) {
+	r.emitHelpType("os_release_eol_timestamp_seconds", "End of support of the installed OS release (only if known)", "gauge")
+	r.buf.WriteString(fmt.Sprintf("os_release_eol_timestamp_seconds %d\n", ts))
+}
+
+func (r *Registry) SetReleaseSupported(ok bool) {
+	r.emitHelpType("os_release_supported", "Whether the OS release is still supported (1 if its end of life is unknown)", "gauge")
+	if ok {
+		r.buf.WriteString("os_release_supported 1\n")
+	} else {
+		r.buf.WriteString("os_release_supported 0\n")
+	}
+}
+
+// SetReleaseUpgrade exposes the newest release the installed one can be
+// upgraded to; target "" reports that none is available.
+func (r *Registry) SetReleaseUpgrade(target string) {
+	r.emitHelpType("os_release_upgrade_available", "Whether an upgrade to a newer OS release is available", "gauge")
+	if target == "" {
+		r.buf.WriteString("os_release_upgrade_available{target_version=\"\"} 0\n")
+		return
+	}
+	r.buf.WriteString(fmt.Sprintf("os_release_upgrade_available{target_version=%q} 1\n", target))
+}
+
+// SetTimeToPatch exposes how long updates were pending before they were
+// applied, as a histogram over the bucket bounds with per-bucket counts
+// (+Inf last).
+func (r *Registry) SetTimeToPatch(manager, class string, bounds []float64, counts []uint64, sum float64, count uint64) {
+	r.emitHelpType("os_updates_time_to_patch_seconds", "Time pending updates took to be applied, across runs", "histogram")
+	cum := uint64(0)
+	for i, n := range counts {
+		cum += n
+		le := "+Inf"
+		if i < len(bounds) {
+			le = fmt.Sprintf("%.0f", bounds[i])
+		}
+		r.buf.WriteString(fmt.Sprintf("os_updates_time_to_patch_seconds_bucket{manager=%q,class=%q,le=%q} %d\n", manager, class, le, cum))
+	}
+	r.buf.WriteString(fmt.Sprintf("os_updates_time_to_patch_seconds_sum{manager=%q,class=%q} %.0f\n", manager, class, sum))
+	r.buf.WriteString(fmt.Sprintf("os_updates_time_to_patch_seconds_count{manager=%q,class=%q} %d\n", manager, class, count))
+}
+
+func (r *Registry) SetLastUpgrade(manager string, ts int64) {
+	r.emitHelpType("os_updates_last_upgrade_timestamp_seconds", "Time of the last package upgrade in the package manager history", "gauge")
+	r.buf.WriteString(fmt.Sprintf("os_updates_last_upgrade_timestamp_seconds{manager=%q} %d\n", manager, ts))
+}
+
+func (r *Registry) SetLastSecurityUpgrade(manager string, ts int64) {
+	r.emitHelpType("os_updates_last_security_upgrade_timestamp_seconds", "Time of the last security upgrade in the package manager history", "gauge")
+	r.buf.WriteString(fmt.Sprintf("os_updates_last_security_upgrade_timestamp_seconds{manager=%q} %d\n", manager, ts))
+}
+
+// SetUpgradedPackages exposes the packages upgraded within window (24h, 7d).
+func (r *Registry) SetUpgradedPackages(manager, window string, n int) {
+	r.emitHelpType("os_updates_upgraded_packages", "Packages upgraded within the window according to the package manager history", "gauge")
+	r.buf.WriteString(fmt.Sprintf("os_updates_upgraded_packages{manager=%q,window=%q} %d\n", manager, window, n))
+}
+
 func (r *Registry) SetCompliant(ok bool) {
 	r.emitHelpType("os_updates_compliant", "Compliance according to patch threshold", "gauge")
 	if ok {
@@ -108,9 +245,34 @@ func (r *Registry) SetCompliantEffective(ok bool) {
 	}
 }
 
-func (r *Registry) SetRiskScore(manager string, v int) {
+// SetSLA exposes the patch SLA compliance of a class: the pending updates
+// past their deadline and the time until the next one breaches.
+func (r *Registry) SetSLA(class string, breached int, remainingSeconds float64) {
+	r.emitHelpType("os_updates_sla_breached", "Pending updates older than the patch SLA of their class", "gauge")
+	r.buf.WriteString(fmt.Sprintf("os_updates_sla_breached{class=%q} %d\n", class, breached))
+	r.emitHelpType("os_updates_sla_remaining_seconds", "Time until the next pending update breaches the patch SLA of its class, negative once one has (full SLA if none is pending)", "gauge")
+	r.buf.WriteString(fmt.Sprintf("os_updates_sla_remaining_seconds{class=%q} %.0f\n", class, remainingSeconds))
+}
+
+func (r *Registry) SetManagerError(manager string, on bool) {
+	r.emitHelpType("os_updates_pkgmgr_error", "Package manager collection error (one series per manager)", "gauge")
+	v := 0
+	if on {
+		v = 1
+	}
+	r.buf.WriteString(fmt.Sprintf("os_updates_pkgmgr_error{manager=%q} %d\n", manager, v))
+}
+
+func (r *Registry) SetRiskScore(manager string, v float64) {
 	r.emitHelpType("os_updates_risk_score", "Weighted risk score for pending updates", "gauge")
-	r.buf.WriteString(fmt.Sprintf("os_updates_risk_score{manager=%q} %d\n", manager, v))
+	r.buf.WriteString(fmt.Sprintf("os_updates_risk_score{manager=%q} %g\n", manager, v))
+}
+
+// SetRiskComponent exposes the points of one risk model factor, summed over
+// the managers.
+func (r *Registry) SetRiskComponent(factor string, points float64) {
+	r.emitHelpType("os_updates_risk_score_component", "Points of one risk model factor", "gauge")
+	r.buf.WriteString(fmt.Sprintf("os_updates_risk_score_component{factor=%q} %g\n", factor, points))
 }
 
 func (r *Registry) SetRepoTotals(manager string, total, unreachable int) {
@@ -178,10 +340,33 @@ func (r *Registry) SetStageDuration(stage string, d time.Duration) {
 	r.buf.WriteString(fmt.Sprintf("os_updates_stage_duration_seconds{stage=%q} %.3f\n", stage, d.Seconds()))
 }
 
+func (r *Registry) SetCommandDuration(command string, d time.Duration) {
+	r.emitHelpType("os_updates_command_duration_seconds", "Run duration per package manager command (summed over runs)", "gauge")
+	r.buf.WriteString(fmt.Sprintf("os_updates_command_duration_seconds{command=%q} %.3f\n", command, d.Seconds()))
+}
+
+// SetCommandExitCode exposes the exit code of the last run of command.
+func (r *Registry) SetCommandExitCode(command string, code int) {
+	r.emitHelpType("os_updates_command_exit_code", "Exit code of the last run per package manager command, non-zero not necessarily a failure (dnf check-update: 100 = updates pending; -1 = not started or killed)", "gauge")
+	r.buf.WriteString(fmt.Sprintf("os_updates_command_exit_code{command=%q} %d\n", command, code))
+}
+
+// SetCommandRuns exposes how many runs of command exited with code.
+func (r *Registry) SetCommandRuns(command string, code, runs int) {
+	r.emitHelpType("os_updates_command_runs", "Runs per package manager command and exit code, non-zero not necessarily a failure (dnf check-update: 100 = updates pending; -1 = not started or killed)", "gauge")
+	r.buf.WriteString(fmt.Sprintf("os_updates_command_runs{command=%q,exit_code=\"%d\"} %d\n", command, code, runs))
+}
+
 func (r *Registry) SetRunDurations(total time.Duration) {
 	r.emitHelpType("os_updates_run_duration_seconds", "Total run duration", "gauge")
 	r.buf.WriteString(fmt.Sprintf("os_updates_run_duration_seconds %.3f\n", total.Seconds()))
 	r.SetStageDuration("total", total)
 }
 
-func (r *Registry) Render() string { return r.buf.String() }
+func (r *Registry) Render() string {
+	var sb strings.Builder
+	for _, name := range r.order {
+		sb.WriteString(r.families[name].String())
+	}
+	return sb.String()
+}
@@ -3,17 +3,21 @@ package reboot
 import (
 	"context"
 	"os"
-	"os/exec"
+	"path/filepath"
 	"strings"
+
+	"github.com/R4VXN/os-updates-exporter/internal/runner"
 )
 
-// Detect returns (reboot_required, reason).
+// Detect returns (reboot_required, reason) from generic, manager independent signals
+// below root ("" = /).
 // reason is one of: kernel, libc, systemd, other, unknown (best-effort).
-func Detect(manager string, ctx context.Context) (bool, string) {
+// Package manager specific checks live in the collector backends (see NeedsRestarting, ZypperPS).
+func Detect(root string) (bool, string) {
 	// Debian/Ubuntu signal
-	if _, err := os.Stat("/var/run/reboot-required"); err == nil {
+	if _, err := os.Stat(filepath.Join(root, "/var/run/reboot-required")); err == nil {
 		reason := "unknown"
-		if b, err := os.ReadFile("/var/run/reboot-required.pkgs"); err == nil {
+		if b, err := os.ReadFile(filepath.Join(root, "/var/run/reboot-required.pkgs")); err == nil {
 			l := strings.ToLower(string(b))
 			switch {
 			case strings.Contains(l, "linux-image") || strings.Contains(l, "linux-headers") || strings.Contains(l, "kernel"):
@@ -28,38 +32,42 @@ func Detect(manager string, ctx context.Context) (bool, string) {
 		}
 		return true, reason
 	}
+	return false, "unknown"
+}
 
-	// RHEL/Fedora best-effort needs-restarting
-	if (manager == "dnf" || manager == "yum") && has("needs-restarting") {
-		cmd := exec.CommandContext(ctx, "bash", "-lc", "needs-restarting -r >/dev/null 2>&1; echo $?")
-		out, _ := cmd.CombinedOutput()
-		s := strings.TrimSpace(string(out))
-		// exit 1 means reboot required
-		if strings.HasSuffix(s, "1") {
-			return true, "kernel"
-		}
+// NeedsRestarting is the RHEL/Fedora best-effort check via needs-restarting -r.
+func NeedsRestarting(ctx context.Context, r runner.Runner) (bool, string) {
+	if !r.Has("needs-restarting") {
+		return false, "unknown"
 	}
-
-	// SUSE best-effort: zypper ps -s (processes using deleted files)
-	if manager == "zypper" && has("zypper") {
-		cmd := exec.CommandContext(ctx, "bash", "-lc", "LANG=C zypper ps -s 2>/dev/null | head -n 80 || true")
-		out, _ := cmd.CombinedOutput()
-		l := strings.ToLower(string(out))
-		if strings.Contains(l, "kernel") {
-			return true, "kernel"
-		}
-		if strings.Contains(l, "systemd") {
-			return true, "systemd"
-		}
-		if strings.TrimSpace(string(out)) != "" {
-			return true, "other"
-		}
+	// exit 1 means reboot required
+	if res := r.Run(ctx, "needs-restarting", "-r"); res.Err == nil && res.ExitCode == 1 {
+		return true, "kernel"
 	}
-
 	return false, "unknown"
 }
 
-func has(bin string) bool {
-	_, err := exec.LookPath(bin)
-	return err == nil
+// ZypperPS is the SUSE best-effort check: zypper ps -s (processes using deleted files).
+// Only the first 80 lines are considered.
+func ZypperPS(ctx context.Context, r runner.Runner) (bool, string) {
+	if !r.Has("zypper") {
+		return false, "unknown"
+	}
+	lines := strings.SplitN(r.Run(ctx, "zypper", "ps", "-s").Stdout, "\n", 81)
+	if len(lines) > 80 {
+		lines = lines[:80]
+	}
+	out := strings.Join(lines, "\n")
+	if !strings.Contains(out, "|") {
+		// no process table, e.g. "No processes using deleted files found."
+		return false, "unknown"
+	}
+	l := strings.ToLower(out)
+	if strings.Contains(l, "kernel") {
+		return true, "kernel"
+	}
+	if strings.Contains(l, "systemd") {
+		return true, "systemd"
+	}
+	return true, "other"
 }
@@ -0,0 +1,133 @@
+// Package risk computes the risk score of a host from weighted factors.
+package risk
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Factors of the risk model. The per-manager score (os_updates_risk_score)
+// sums the manager factors; Reboot and EOL concern the whole host and only
+// appear in the component breakdown.
+const (
+	Bugfix = "bugfix" // pending bugfix updates
+
+	// pending security updates per severity
+	SecurityCritical  = "security_critical"
+	SecurityImportant = "security_important"
+	SecurityModerate  = "security_moderate"
+	SecurityLow       = "security_low"
+	SecurityUnknown   = "security_unknown"
+
+	Kernel          = "kernel"            // security updates while a kernel reboot is pending
+	RepoUnreachable = "repo_unreachable"  // unreachable repositories
+	SecurityAgeDays = "security_age_days" // days the oldest security update has been pending
+	KEV             = "kev"               // pending CVEs in the known exploited vulnerabilities catalog
+
+	Reboot = "reboot" // 1 if a reboot is pending
+	EOL    = "eol"    // 1 if the release is past its end of life
+)
+
+// Factor is the weight of one factor and the cap of its points (0 = none).
+type Factor struct {
+	Name   string
+	Weight float64
+	Cap    float64
+}
+
+// Model is an ordered list of factors.
+type Model []Factor
+
+// Default returns the built-in model: bugfix 1, security by severity
+// (critical 10, important 5, moderate 3, low 1, unknown 5), kernel 5 and the
+// remaining factors disabled.
+func Default() Model {
+	return Model{
+		{Name: Bugfix, Weight: 1},
+		{Name: SecurityCritical, Weight: 10},
+		{Name: SecurityImportant, Weight: 5},
+		{Name: SecurityModerate, Weight: 3},
+		{Name: SecurityLow, Weight: 1},
+		{Name: SecurityUnknown, Weight: 5},
+		{Name: Kernel, Weight: 5},
+		{Name: RepoUnreachable},
+		{Name: SecurityAgeDays},
+		{Name: KEV},
+		{Name: Reboot},
+		{Name: EOL},
+	}
+}
+
+// Load returns the default model with the factors of file ("" = none)
+// replaced. The file has "FACTOR WEIGHT [CAP]" rows; "#" starts a comment.
+func Load(file string) (Model, error) {
+	m := Default()
+	if file == "" {
+		return m, nil
+	}
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return m, err
+	}
+	for i, ln := range strings.Split(string(b), "\n") {
+		ln, _, _ = strings.Cut(ln, "#")
+		fields := strings.Fields(ln)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) > 3 || len(fields) < 2 {
+			return m, fmt.Errorf("%s:%d: want FACTOR WEIGHT [CAP]", file, i+1)
+		}
+		f := Factor{Name: fields[0]}
+		if f.Weight, err = strconv.ParseFloat(fields[1], 64); err != nil {
+			return m, fmt.Errorf("%s:%d: weight: %w", file, i+1, err)
+		}
+		if len(fields) == 3 {
+			if f.Cap, err = strconv.ParseFloat(fields[2], 64); err != nil || f.Cap < 0 {
+				return m, fmt.Errorf("%s:%d: invalid cap %q", file, i+1, fields[2])
+			}
+		}
+		j := m.index(f.Name)
+		if j < 0 {
+			return m, fmt.Errorf("%s:%d: unknown factor %q", file, i+1, f.Name)
+		}
+		m[j] = f
+	}
+	return m, nil
+}
+
+func (m Model) index(name string) int {
+	for i, f := range m {
+		if f.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Component is the contribution of one factor to a score.
+type Component struct {
+	Factor
+	Value  float64
+	Points float64
+}
+
+// Score weighs the given factor values. Components are returned in model
+// order for every factor present in values.
+func (m Model) Score(values map[string]float64) (total float64, comps []Component) {
+	for _, f := range m {
+		v, ok := values[f.Name]
+		if !ok {
+			continue
+		}
+		pts := v * f.Weight
+		if f.Cap > 0 && pts > f.Cap {
+			pts = f.Cap
+		}
+		comps = append(comps, Component{Factor: f, Value: v, Points: pts})
+		total += pts
+	}
+	return total, comps
+}
@@ -0,0 +1,40 @@
+package risk
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadAndScore(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "risk.txt")
+	model := "# team policy\nsecurity_critical 20 50\nsecurity_age_days 0.5 10\nreboot 3\n"
+	if err := os.WriteFile(file, []byte(model), 0644); err != nil {
+		t.Fatal(err)
+	}
+	m, err := Load(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	total, comps := m.Score(map[string]float64{
+		Bugfix:           2,
+		SecurityCritical: 3,  // 60, capped at 50
+		SecurityAgeDays:  40, // 20, capped at 10
+		Reboot:           1,
+	})
+	if total != 2+50+10+3 {
+		t.Errorf("total = %g, want 65", total)
+	}
+	if len(comps) != 4 || comps[0].Name != Bugfix || comps[len(comps)-1].Name != Reboot {
+		t.Errorf("components = %+v", comps)
+	}
+
+	for _, bad := range []string{"unknown_factor 1\n", "bugfix\n", "bugfix x\n", "bugfix 1 -1\n"} {
+		if err := os.WriteFile(file, []byte(bad), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Load(file); err == nil {
+			t.Errorf("Load(%q) succeeded", bad)
+		}
+	}
+}
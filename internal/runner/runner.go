@@ -0,0 +1,290 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// DefaultPath is the PATH commands are looked up in and run with.
+const DefaultPath = "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+
+// Runner runs package manager commands.
+type Runner interface {
+	// Run executes name with args and waits for it to finish.
+	Run(ctx context.Context, name string, args ...string) Result
+	// Stream executes name with args and passes its stdout to fn instead of
+	// keeping it in Result.Stdout. When fn returns, the command is killed if it
+	// is still running; that counts as success, the output was not wanted.
+	Stream(ctx context.Context, fn func(io.Reader), name string, args ...string) Result
+	// Has reports whether name can be run.
+	Has(name string) bool
+}
+
+// Recorder is a Runner that keeps the results of all runs.
+type Recorder interface {
+	Runner
+	Runs() []Result
+}
+
+// Result is the outcome of one command.
+type Result struct {
+	// Command is the metric label: the binary and its subcommand, see Label.
+	Command  string
+	Stdout   string
+	Stderr   string
+	ExitCode int // -1 if the command could not be started or was killed
+	Duration time.Duration
+	// Err is set if the command could not be started or was killed; a non-zero
+	// exit status alone is not an error, see Check.
+	Err error
+}
+
+// Check returns Err, or an error if the exit code is not one of ok (default 0).
+func (r Result) Check(ok ...int) error {
+	if r.Err != nil {
+		return r.Err
+	}
+	if len(ok) == 0 {
+		ok = []int{0}
+	}
+	for _, c := range ok {
+		if r.ExitCode == c {
+			return nil
+		}
+	}
+	msg, _, _ := strings.Cut(strings.TrimSpace(r.Stderr), "\n")
+	if msg == "" {
+		return fmt.Errorf("%s: exit status %d", r.Command, r.ExitCode)
+	}
+	return fmt.Errorf("%s: exit status %d: %s", r.Command, r.ExitCode, msg)
+}
+
+// Exec runs binaries directly, without a shell, with a fixed environment.
+// Every command runs in its own process group which is killed as a whole when
+// the context is done. All results are recorded for the command metrics.
+type Exec struct {
+	Env []string
+	// Root points the package managers at the system below Root, see RootArgs.
+	Root string
+
+	mu   sync.Mutex
+	runs []Result
+}
+
+// New returns an Exec with DefaultEnv.
+func New() *Exec {
+	return &Exec{Env: DefaultEnv()}
+}
+
+// DefaultEnv is LANG=C, LC_ALL=C and DefaultPath; proxy settings of the
+// exporter's environment are passed through.
+func DefaultEnv() []string {
+	env := []string{"LANG=C", "LC_ALL=C", "PATH=" + DefaultPath}
+	for _, k := range []string{"http_proxy", "https_proxy", "no_proxy", "HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY"} {
+		if v, ok := os.LookupEnv(k); ok {
+			env = append(env, k+"="+v)
+		}
+	}
+	return env
+}
+
+func (e *Exec) Has(name string) bool {
+	_, err := lookPath(name)
+	return err == nil
+}
+
+func (e *Exec) Run(ctx context.Context, name string, args ...string) Result {
+	return e.run(ctx, nil, name, args...)
+}
+
+func (e *Exec) Stream(ctx context.Context, fn func(io.Reader), name string, args ...string) Result {
+	return e.run(ctx, fn, name, args...)
+}
+
+// run runs the command, collecting stdout in the result if fn is nil.
+func (e *Exec) run(ctx context.Context, fn func(io.Reader), name string, args ...string) (res Result) {
+	res = Result{Command: Label(name, args...), ExitCode: -1}
+	start := time.Now()
+	defer func() {
+		res.Duration = time.Since(start)
+		e.mu.Lock()
+		e.runs = append(e.runs, res)
+		e.mu.Unlock()
+	}()
+
+	path, err := lookPath(name)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	if e.Root != "" {
+		args = append(RootArgs(name, e.Root), args...)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.Env = e.Env
+	cmd.Stderr = &stderr
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		// negative pid: the whole process group
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = 5 * time.Second
+
+	stopped := false
+	if fn == nil {
+		cmd.Stdout = &stdout
+		err = cmd.Run()
+	} else {
+		var pipe io.ReadCloser
+		if pipe, err = cmd.StdoutPipe(); err == nil {
+			err = cmd.Start()
+		}
+		if err == nil {
+			fn(pipe)
+			// the rest of the output is not wanted
+			stopped = syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL) == nil
+			err = cmd.Wait()
+		}
+	}
+	res.Stdout, res.Stderr = stdout.String(), stderr.String()
+	var exitErr *exec.ExitError
+	switch {
+	case ctx.Err() != nil:
+		res.Err = fmt.Errorf("%s: %w", res.Command, ctx.Err())
+	case errors.As(err, &exitErr) && exitErr.Exited():
+		res.ExitCode = exitErr.ExitCode()
+	case errors.As(err, &exitErr) && stopped:
+		res.ExitCode = 0
+	case err != nil:
+		res.Err = fmt.Errorf("%s: %w", res.Command, err)
+	default:
+		res.ExitCode = 0
+	}
+	return res
+}
+
+// RootArgs returns the options that make the package manager name operate on
+// the system below root instead of /. Other commands get none.
+func RootArgs(name, root string) []string {
+	switch name {
+	case "apt", "apt-get", "apt-cache", "apt-mark":
+		// the dpkg status file is not below Dir::State
+		return []string{"-o", "Dir=" + root, "-o", "Dir::State::status=" + filepath.Join(root, "var/lib/dpkg/status")}
+	case "dnf", "yum":
+		return []string{"--installroot=" + root}
+	case "zypper", "rpm", "apk":
+		return []string{"--root", root}
+	case "pacman":
+		// --sysroot chroots and needs privileges; --root is deprecated
+		return []string{"--config", filepath.Join(root, "etc/pacman.conf"), "--dbpath", filepath.Join(root, "var/lib/pacman")}
+	}
+	return nil
+}
+
+// Runs returns the results recorded so far.
+func (e *Exec) Runs() []Result {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]Result(nil), e.runs...)
+}
+
+// lookPath searches DefaultPath, not the exporter's PATH.
+func lookPath(name string) (string, error) {
+	if strings.Contains(name, "/") {
+		return name, executable(name)
+	}
+	for _, dir := range filepath.SplitList(DefaultPath) {
+		p := filepath.Join(dir, name)
+		if executable(p) == nil {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("%s: %w", name, exec.ErrNotFound)
+}
+
+func executable(path string) error {
+	st, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if st.IsDir() || st.Mode()&0111 == 0 {
+		return os.ErrPermission
+	}
+	return nil
+}
+
+// Label returns the metric label of a command: the binary and the first
+// argument that looks like a subcommand ("dnf check-update", "zypper lu"). For
+// tools whose operation is an option ("rpm -qa", "pacman -Qu") the first
+// argument is used instead.
+func Label(name string, args ...string) string {
+	for _, a := range args {
+		if a == "--" {
+			break
+		}
+		if isWord(a) {
+			return name + " " + a
+		}
+	}
+	if len(args) > 0 && len(args[0]) > 1 && args[0][0] == '-' && args[0][1] != '-' {
+		return name + " " + args[0]
+	}
+	return name
+}
+
+func isWord(s string) bool {
+	if s == "" || s[0] == '-' {
+		return false
+	}
+	for _, c := range s {
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+// Stat is the per-command summary exposed as metrics.
+type Stat struct {
+	Command  string
+	Duration time.Duration
+	// ExitCode is the exit code of the last run (-1: not started or killed).
+	ExitCode int
+	// ExitCodes counts the runs per exit code.
+	ExitCodes map[int]int
+}
+
+// Summarize folds results by command: durations are summed, the runs are
+// counted per exit code and the exit code of the last run, in the order of
+// runs, is kept. A non-zero exit code is not necessarily a
+// failure (dnf check-update exits 100 if updates are pending), that is up to
+// the caller's Check.
+func Summarize(runs []Result) []Stat {
+	idx := map[string]int{}
+	stats := []Stat{}
+	for _, r := range runs {
+		i, ok := idx[r.Command]
+		if !ok {
+			i = len(stats)
+			idx[r.Command] = i
+			stats = append(stats, Stat{Command: r.Command, ExitCodes: map[int]int{}})
+		}
+		stats[i].Duration += r.Duration
+		stats[i].ExitCode = r.ExitCode
+		stats[i].ExitCodes[r.ExitCode]++
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Command < stats[j].Command })
+	return stats
+}
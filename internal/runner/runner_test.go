@@ -0,0 +1,166 @@
+package runner
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestLabel(t *testing.T) {
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"dnf", "-q", "check-update"}, "dnf check-update"},
+		{[]string{"zypper", "-q", "info", "-t", "patch", "--", "SUSE-2024-1"}, "zypper info"},
+		{[]string{"apt-get", "-s", "-o", "Debug::NoLocking=1", "dist-upgrade"}, "apt-get dist-upgrade"},
+		{[]string{"rpm", "-qa", "--qf", "%{NAME}"}, "rpm -qa"},
+		{[]string{"pacman", "-Q", "--", "linux"}, "pacman -Q"},
+		{[]string{"needs-restarting", "-r"}, "needs-restarting -r"},
+		{[]string{"snap"}, "snap"},
+	} {
+		if got := Label(tc.args[0], tc.args[1:]...); got != tc.want {
+			t.Errorf("Label(%q) = %q, want %q", tc.args, got, tc.want)
+		}
+	}
+}
+
+func TestRootArgs(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		want []string
+	}{
+		{"apt", []string{"-o", "Dir=/host", "-o", "Dir::State::status=/host/var/lib/dpkg/status"}},
+		{"dnf", []string{"--installroot=/host"}},
+		{"zypper", []string{"--root", "/host"}},
+		{"pacman", []string{"--config", "/host/etc/pacman.conf", "--dbpath", "/host/var/lib/pacman"}},
+		{"dpkg-deb", nil},
+	} {
+		if got := RootArgs(tc.name, "/host"); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("RootArgs(%q) = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	stats := Summarize([]Result{
+		{Command: "rpm -q", ExitCode: 1, Duration: 2},
+		{Command: "dnf check-update", ExitCode: 100, Duration: 5},
+		{Command: "rpm -q", ExitCode: 0, Duration: 3},
+	})
+	want := []Stat{
+		{Command: "dnf check-update", Duration: 5, ExitCode: 100, ExitCodes: map[int]int{100: 1}},
+		// the last run of rpm -q exited 0
+		{Command: "rpm -q", Duration: 5, ExitCode: 0, ExitCodes: map[int]int{0: 1, 1: 1}},
+	}
+	if !reflect.DeepEqual(stats, want) {
+		t.Errorf("got %+v, want %+v", stats, want)
+	}
+	// the exit code is reported as is, whether or not the caller accepts it
+	if (Result{Command: "dnf check-update", ExitCode: 100}).Check(0, 100) != nil {
+		t.Error("exit code 100 of dnf check-update rejected")
+	}
+}
+
+func TestStream(t *testing.T) {
+	e := New()
+	if !e.Has("sh") {
+		t.Skip("no sh")
+	}
+	first := ""
+	// the command never ends on its own: it is killed once the first line is read
+	res := e.Stream(context.Background(), func(r io.Reader) {
+		first, _ = bufio.NewReader(r).ReadString('\n')
+	}, "sh", "-c", "echo first; while :; do echo more; done")
+	if first != "first\n" {
+		t.Errorf("first line = %q", first)
+	}
+	if err := res.Check(); err != nil {
+		t.Errorf("Check: %v", err)
+	}
+	if res.Stdout != "" {
+		t.Errorf("stdout kept: %q", res.Stdout)
+	}
+}
+
+func TestRun(t *testing.T) {
+	e := New()
+	if !e.Has("sh") {
+		t.Skip("no sh")
+	}
+	res := e.Run(context.Background(), "sh", "-c", "echo out; echo err >&2; exit 100")
+	if res.Err != nil || res.ExitCode != 100 {
+		t.Fatalf("exit code %d, err %v", res.ExitCode, res.Err)
+	}
+	// stderr is not mixed into the parsed output
+	if res.Stdout != "out\n" || res.Stderr != "err\n" {
+		t.Errorf("stdout %q, stderr %q", res.Stdout, res.Stderr)
+	}
+	if err := res.Check(); err == nil || !strings.Contains(err.Error(), "exit status 100: err") {
+		t.Errorf("Check: %v", err)
+	}
+	// the fixed environment, not the exporter's
+	t.Setenv("OS_UPDATES_TEST", "leak")
+	if res := e.Run(context.Background(), "sh", "-c", "echo $LC_ALL $OS_UPDATES_TEST"); res.Stdout != "C\n" {
+		t.Errorf("environment: %q", res.Stdout)
+	}
+	if res := e.Run(context.Background(), "no-such-command-os-updates"); res.Err == nil || res.ExitCode != -1 {
+		t.Errorf("missing command: exit code %d, err %v", res.ExitCode, res.Err)
+	}
+	if got := len(e.Runs()); got != 3 {
+		t.Errorf("%d runs recorded, want 3", got)
+	}
+}
+
+func TestRunTimeoutKillsProcessGroup(t *testing.T) {
+	e := New()
+	if !e.Has("sh") || !e.Has("sleep") {
+		t.Skip("no sh or sleep")
+	}
+	pidFile := filepath.Join(t.TempDir(), "pid")
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	// the grandchild writes its pid and would outlive a kill of sh alone
+	res := e.Run(ctx, "sh", "-c", "sleep 30 & echo $! > "+pidFile+"; wait")
+	if res.Err == nil || res.ExitCode != -1 {
+		t.Fatalf("exit code %d, err %v", res.ExitCode, res.Err)
+	}
+	if res.Duration > 5*time.Second {
+		t.Errorf("took %v", res.Duration)
+	}
+	b, err := os.ReadFile(pidFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pid := 0
+	if _, err := fmt.Sscan(string(b), &pid); err != nil {
+		t.Fatal(err)
+	}
+	// the killed grandchild may linger as a zombie until it is reaped
+	deadline := time.Now().Add(2 * time.Second)
+	for syscall.Kill(pid, 0) == nil && !zombie(pid) {
+		if time.Now().After(deadline) {
+			_ = syscall.Kill(pid, syscall.SIGKILL)
+			t.Fatalf("grandchild %d still running", pid)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func zombie(pid int) bool {
+	b, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return false
+	}
+	// "pid (comm) state ..."
+	_, rest, _ := strings.Cut(string(b), ") ")
+	return strings.HasPrefix(rest, "Z")
+}
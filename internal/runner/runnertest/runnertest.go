@@ -0,0 +1,129 @@
+// Package runnertest provides a runner.Runner that replays recorded command
+// output, for tests.
+package runnertest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/R4VXN/os-updates-exporter/internal/runner"
+)
+
+// Fake replays a transcript. Commands are matched on the binary and its
+// arguments joined by single spaces; unrecorded commands fail as if the binary
+// was missing. A binary is present (Has) if any recorded command uses it.
+type Fake struct {
+	cmds map[string]runner.Result
+	bins map[string]bool
+
+	mu   sync.Mutex
+	runs []runner.Result
+}
+
+// Load reads a transcript file, see Parse.
+func Load(file string) (*Fake, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(string(b))
+}
+
+// Parse reads a transcript in the style of a shell session:
+//
+//	$ dnf -q check-update
+//	? 100
+//	! stderr line
+//	bash.x86_64  5.1.8-9.el9  baseos
+//
+// "$ " starts a command, an optional "? " line right after it gives the exit
+// code (default 0), "! " lines are stderr and all other lines up to the next
+// command are stdout. Lines before the first command are comments. A command
+// without output only marks its binary as present.
+func Parse(s string) (*Fake, error) {
+	f := &Fake{cmds: map[string]runner.Result{}, bins: map[string]bool{}}
+	key := ""
+	var cur *runner.Result
+	var stdout, stderr []string
+	flush := func() {
+		if cur == nil {
+			return
+		}
+		if len(stdout) > 0 {
+			cur.Stdout = strings.Join(stdout, "\n") + "\n"
+		}
+		if len(stderr) > 0 {
+			cur.Stderr = strings.Join(stderr, "\n") + "\n"
+		}
+		f.cmds[key] = *cur
+		stdout, stderr = nil, nil
+	}
+	lines := strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+	for i, ln := range lines {
+		switch {
+		case strings.HasPrefix(ln, "$ "):
+			flush()
+			argv := strings.Fields(ln[2:])
+			if len(argv) == 0 {
+				return nil, fmt.Errorf("line %d: empty command", i+1)
+			}
+			key = strings.TrimSpace(ln[2:])
+			f.bins[argv[0]] = true
+			cur = &runner.Result{Command: runner.Label(argv[0], argv[1:]...)}
+			if i+1 < len(lines) && strings.HasPrefix(lines[i+1], "? ") {
+				code, err := strconv.Atoi(strings.TrimSpace(lines[i+1][2:]))
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %w", i+2, err)
+				}
+				cur.ExitCode = code
+			}
+		case cur == nil:
+		case strings.HasPrefix(ln, "? ") && strings.HasPrefix(lines[i-1], "$ "):
+		case strings.HasPrefix(ln, "! "):
+			stderr = append(stderr, ln[2:])
+		default:
+			stdout = append(stdout, ln)
+		}
+	}
+	flush()
+	return f, nil
+}
+
+func (f *Fake) Has(name string) bool { return f.bins[name] }
+
+func (f *Fake) Run(ctx context.Context, name string, args ...string) runner.Result {
+	res, ok := f.cmds[strings.Join(append([]string{name}, args...), " ")]
+	if !ok {
+		res = runner.Result{
+			Command:  runner.Label(name, args...),
+			ExitCode: -1,
+			Err:      fmt.Errorf("%s: %w", name, exec.ErrNotFound),
+		}
+	}
+	f.mu.Lock()
+	f.runs = append(f.runs, res)
+	f.mu.Unlock()
+	return res
+}
+
+// Stream replays the recorded stdout of the command to fn.
+func (f *Fake) Stream(ctx context.Context, fn func(io.Reader), name string, args ...string) runner.Result {
+	res := f.Run(ctx, name, args...)
+	if res.Err == nil {
+		fn(strings.NewReader(res.Stdout))
+	}
+	return res
+}
+
+// Runs returns the results of all runs, in order.
+func (f *Fake) Runs() []runner.Result {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]runner.Result(nil), f.runs...)
+}
@@ -15,6 +15,24 @@ type State struct {
 	LastUpdateAvailable  bool  `json:"last_update_available"`
 	LastUpdateRunTS      int64 `json:"last_update_run_ts"`
 	LastUpdateRunSuccess bool  `json:"last_update_run_success"`
+
+	// CVEFirstSeen maps pending CVE IDs to the first run they were seen in.
+	CVEFirstSeen map[string]int64 `json:"cve_first_seen,omitempty"`
+	// KEVFirstSeen maps pending CVE IDs listed in the known exploited
+	// vulnerabilities catalog to the first run they were seen in as such.
+	KEVFirstSeen map[string]int64 `json:"kev_first_seen,omitempty"`
+
+	// PendingPackages maps manager and "name.arch installed-version" to the
+	// pending update of that package, see TrackPackages.
+	PendingPackages map[string]map[string]PendingPackage `json:"pending_packages,omitempty"`
+	// TimeToPatch holds the time-to-patch histograms keyed by "manager/class".
+	TimeToPatch map[string]Histogram `json:"time_to_patch,omitempty"`
+
+	// Last release upgrade check: when, for which installed release ("ID
+	// VERSION_ID") and the release offered ("" = none).
+	ReleaseUpgradeCheckTS int64  `json:"release_upgrade_check_ts,omitempty"`
+	ReleaseUpgradeRelease string `json:"release_upgrade_release,omitempty"`
+	ReleaseUpgradeTarget  string `json:"release_upgrade_target,omitempty"`
 }
 
 type ManagerState struct {
@@ -27,9 +45,29 @@ type ManagerState struct {
 
 	RebootRequired bool `json:"reboot_required"`
 
-	OldestAllSeen      int64 `json:"oldest_all_seen"`
-	OldestSecuritySeen int64 `json:"oldest_security_seen"`
-	OldestBugfixSeen   int64 `json:"oldest_bugfix_seen"`
+	// Oldest*Seen are only read from state files written before per-package
+	// tracking, see TrackPackages.
+	OldestAllSeen      int64 `json:"oldest_all_seen,omitempty"`
+	OldestSecuritySeen int64 `json:"oldest_security_seen,omitempty"`
+	OldestBugfixSeen   int64 `json:"oldest_bugfix_seen,omitempty"`
+}
+
+// PendingPackage is a pending update tracked across runs.
+type PendingPackage struct {
+	Class     string `json:"class"`
+	FirstSeen int64  `json:"first_seen"`
+}
+
+// TimeToPatchBuckets are the upper bounds (seconds) of the time-to-patch
+// histograms: 1h, 6h, 1d, 3d, 7d, 14d, 30d, 90d.
+var TimeToPatchBuckets = []float64{3600, 21600, 86400, 259200, 604800, 1209600, 2592000, 7776000}
+
+// Histogram is a cumulative histogram over TimeToPatchBuckets.
+type Histogram struct {
+	// Counts holds the observations per bucket (not cumulative) and +Inf last.
+	Counts []uint64 `json:"counts"`
+	Sum    float64  `json:"sum"`
+	Count  uint64   `json:"count"`
 }
 
 func New() *State {
@@ -83,50 +121,126 @@ func (s *State) SetManager(name string, ms ManagerState) {
 	s.Managers[name] = ms
 }
 
-func (s *State) ensureManager(name string) ManagerState {
-	ms := s.GetManager(name)
-	s.SetManager(name, ms)
-	return ms
+// TrackPackages records the first-seen time of the pending updates of manager,
+// keyed by "name.arch installed-version" with their class, and forgets those
+// no longer pending: their installed version changed, so the time they were
+// pending is added to the time-to-patch histogram of their class. It returns
+// the tracked first-seen times of the pending updates.
+//
+// Packages pending on the first run after an upgrade from a state file without
+// per-package tracking inherit the oldest first-seen time of their class.
+func (s *State) TrackPackages(manager string, pending map[string]string, now int64) map[string]int64 {
+	if s.PendingPackages == nil {
+		s.PendingPackages = map[string]map[string]PendingPackage{}
+	}
+	prev, tracked := s.PendingPackages[manager]
+	ms := s.GetManager(manager)
+	next := map[string]PendingPackage{}
+	seen := map[string]int64{}
+	for key, class := range pending {
+		first := now
+		if p, ok := prev[key]; ok && p.FirstSeen > 0 {
+			first = p.FirstSeen
+		} else if !tracked {
+			first = inherited(ms, class, now)
+		}
+		next[key] = PendingPackage{Class: class, FirstSeen: first}
+		seen[key] = first
+	}
+	for key, p := range prev {
+		if _, ok := next[key]; !ok && p.FirstSeen > 0 && now >= p.FirstSeen {
+			s.observeTimeToPatch(manager+"/"+p.Class, float64(now-p.FirstSeen))
+		}
+	}
+	s.PendingPackages[manager] = next
+	return seen
 }
 
-func (s *State) Oldest(manager, typ string) int64 {
-	ms := s.GetManager(manager)
-	switch typ {
+// inherited returns the class-wide first-seen time of state files written
+// before per-package tracking, or now.
+func inherited(ms ManagerState, class string, now int64) int64 {
+	ts := ms.OldestAllSeen
+	switch class {
 	case "security":
-		return ms.OldestSecuritySeen
+		ts = ms.OldestSecuritySeen
 	case "bugfix":
-		return ms.OldestBugfixSeen
-	default:
-		return ms.OldestAllSeen
+		ts = ms.OldestBugfixSeen
+	}
+	if ts <= 0 || ts > now {
+		return now
 	}
+	return ts
 }
 
-// UpdateOldestSeen implements pragmatic aging: "oldest seen while pending>0".
-func (s *State) UpdateOldestSeen(manager, typ string, pending int, now int64) float64 {
-	ms := s.ensureManager(manager)
+func (s *State) observeTimeToPatch(key string, seconds float64) {
+	if s.TimeToPatch == nil {
+		s.TimeToPatch = map[string]Histogram{}
+	}
+	h := s.TimeToPatch[key]
+	if len(h.Counts) != len(TimeToPatchBuckets)+1 {
+		// new or written with other buckets: start over
+		h = Histogram{Counts: make([]uint64, len(TimeToPatchBuckets)+1)}
+	}
+	i := 0
+	for i < len(TimeToPatchBuckets) && seconds > TimeToPatchBuckets[i] {
+		i++
+	}
+	h.Counts[i]++
+	h.Sum += seconds
+	h.Count++
+	s.TimeToPatch[key] = h
+}
+
+// TrackCVEs records the first-seen time of newly pending CVEs and returns the
+// first-seen times of pending. CVEs no longer pending are forgotten, unless
+// partial is set: a package manager failed and may not have reported them.
+func (s *State) TrackCVEs(pending []string, partial bool, now int64) map[string]int64 {
+	var seen map[string]int64
+	s.CVEFirstSeen, seen = trackFirstSeen(s.CVEFirstSeen, pending, partial, now)
+	return seen
+}
+
+// TrackKEV does the same as TrackCVEs for the pending CVEs that are known to
+// be exploited. A CVE added to the catalog while pending is first seen when
+// the catalog lists it.
+func (s *State) TrackKEV(pending []string, partial bool, now int64) map[string]int64 {
+	var seen map[string]int64
+	s.KEVFirstSeen, seen = trackFirstSeen(s.KEVFirstSeen, pending, partial, now)
+	return seen
+}
 
-	get := func() *int64 {
-		switch typ {
-		case "security":
-			return &ms.OldestSecuritySeen
-		case "bugfix":
-			return &ms.OldestBugfixSeen
-		default:
-			return &ms.OldestAllSeen
+// trackFirstSeen returns the tracked IDs of the next run and the first-seen
+// times of pending.
+func trackFirstSeen(prev map[string]int64, pending []string, keep bool, now int64) (next, seen map[string]int64) {
+	next, seen = map[string]int64{}, map[string]int64{}
+	if keep {
+		for id, ts := range prev {
+			next[id] = ts
 		}
 	}
-
-	ptr := get()
-	if pending <= 0 {
-		*ptr = 0
-		s.SetManager(manager, ms)
-		return 0
+	for _, id := range pending {
+		if ts, ok := prev[id]; ok && ts > 0 {
+			seen[id] = ts
+		} else {
+			seen[id] = now
+		}
+		next[id] = seen[id]
 	}
-	if *ptr == 0 {
-		*ptr = now
-		s.SetManager(manager, ms)
-		return 0
+	return next, seen
+}
+
+// CachedReleaseUpgrade returns the target of the last release upgrade check if
+// it was done for release less than maxAge seconds ago.
+func (s *State) CachedReleaseUpgrade(release string, now, maxAge int64) (string, bool) {
+	if s.ReleaseUpgradeCheckTS == 0 || s.ReleaseUpgradeRelease != release || now-s.ReleaseUpgradeCheckTS >= maxAge {
+		return "", false
 	}
-	s.SetManager(manager, ms)
-	return float64(now - *ptr)
+	return s.ReleaseUpgradeTarget, true
+}
+
+// SetReleaseUpgrade records a release upgrade check.
+func (s *State) SetReleaseUpgrade(release, target string, now int64) {
+	s.ReleaseUpgradeCheckTS = now
+	s.ReleaseUpgradeRelease = release
+	s.ReleaseUpgradeTarget = target
 }
@@ -0,0 +1,76 @@
+package state
+
+import "testing"
+
+func TestTrackPackages(t *testing.T) {
+	s := New()
+	// state file of an older version: class-wide first-seen times only
+	s.SetManager("dnf", ManagerState{OldestAllSeen: 100, OldestSecuritySeen: 500})
+
+	seen := s.TrackPackages("dnf", map[string]string{
+		"openssl.x86_64 3.0.7-27.el9": "security",
+		"tzdata.noarch 2024a-1.el9":   "bugfix",
+	}, 1000)
+	if seen["openssl.x86_64 3.0.7-27.el9"] != 500 || seen["tzdata.noarch 2024a-1.el9"] != 1000 {
+		t.Errorf("first run: %v", seen)
+	}
+
+	// openssl was applied, a new bugfix update arrived
+	seen = s.TrackPackages("dnf", map[string]string{
+		"tzdata.noarch 2024a-1.el9": "bugfix",
+		"bash.x86_64 5.1.8-9.el9":   "bugfix",
+	}, 2000)
+	if seen["tzdata.noarch 2024a-1.el9"] != 1000 || seen["bash.x86_64 5.1.8-9.el9"] != 2000 {
+		t.Errorf("second run: %v", seen)
+	}
+	h := s.TimeToPatch["dnf/security"]
+	if h.Count != 1 || h.Sum != 1500 || h.Counts[0] != 1 {
+		t.Errorf("security histogram = %+v", h)
+	}
+	if _, ok := s.TimeToPatch["dnf/bugfix"]; ok {
+		t.Error("bugfix update observed before it was applied")
+	}
+
+	// nothing pending any more: both bugfix updates were applied
+	s.TrackPackages("dnf", map[string]string{}, 2000+8*86400)
+	h = s.TimeToPatch["dnf/bugfix"]
+	if h.Count != 2 || h.Counts[5] != 2 {
+		t.Errorf("bugfix histogram = %+v", h)
+	}
+}
+
+func TestTrackKEV(t *testing.T) {
+	s := New()
+	s.TrackKEV([]string{"CVE-2024-36971"}, false, 1000)
+	seen := s.TrackKEV([]string{"CVE-2024-36971", "CVE-2023-4911"}, false, 2000)
+	if seen["CVE-2024-36971"] != 1000 || seen["CVE-2023-4911"] != 2000 {
+		t.Errorf("seen = %v", seen)
+	}
+	// a manager failed: the entries it did not report are kept
+	if seen = s.TrackKEV([]string{"CVE-2023-4911"}, true, 2500); len(seen) != 1 || s.KEVFirstSeen["CVE-2024-36971"] != 1000 {
+		t.Errorf("seen = %v, tracked = %v", seen, s.KEVFirstSeen)
+	}
+	// applied, then pending again: the age starts over
+	s.TrackKEV(nil, false, 3000)
+	if seen = s.TrackKEV([]string{"CVE-2024-36971"}, false, 4000); seen["CVE-2024-36971"] != 4000 {
+		t.Errorf("seen = %v", seen)
+	}
+}
+
+func TestTrackCVEs(t *testing.T) {
+	s := New()
+	s.TrackCVEs([]string{"CVE-2024-5535", "CVE-2024-6387"}, false, 1000)
+	// a manager failed: CVE-2024-6387 is kept although it was not reported
+	seen := s.TrackCVEs([]string{"CVE-2024-5535"}, true, 2000)
+	if len(seen) != 1 || seen["CVE-2024-5535"] != 1000 {
+		t.Errorf("seen = %v", seen)
+	}
+	if seen = s.TrackCVEs([]string{"CVE-2024-5535", "CVE-2024-6387"}, false, 3000); seen["CVE-2024-6387"] != 1000 {
+		t.Errorf("seen = %v", seen)
+	}
+	// a complete run forgets the CVEs no longer pending
+	s.TrackCVEs([]string{"CVE-2024-5535"}, false, 4000)
+	if _, ok := s.CVEFirstSeen["CVE-2024-6387"]; ok {
+		t.Errorf("CVEFirstSeen = %v", s.CVEFirstSeen)
+	}
+}
@@ -0,0 +1,61 @@
+package version
+
+import "strings"
+
+// Bumps lists the kinds of version change returned by Bump.
+var Bumps = []string{"epoch", "major", "minor", "patch", "release"}
+
+// Bump classifies the change from installed to candidate. Both are split into
+// epoch, upstream version and release/revision (the part after the last '-');
+// the upstream version is compared component-wise: a change in the first
+// component is "major", the second "minor", any later one "patch". If only the
+// release/revision changed (a rebuild or distro patch) it is "release".
+// It returns "" if the versions are equal or installed is unknown.
+func Bump(installed, candidate string) string {
+	if installed == "" || candidate == "" || installed == candidate {
+		return ""
+	}
+	ei, ui, ri := splitEVR(installed)
+	ec, uc, rc := splitEVR(candidate)
+	if compareNum(ei, ec) != 0 {
+		return "epoch"
+	}
+	if ui == uc {
+		if ri != rc {
+			return "release"
+		}
+		return ""
+	}
+	pi, pc := components(ui), components(uc)
+	for k := 0; k < len(pi) || k < len(pc); k++ {
+		if k < len(pi) && k < len(pc) && pi[k] == pc[k] {
+			continue
+		}
+		switch k {
+		case 0:
+			return "major"
+		case 1:
+			return "minor"
+		default:
+			return "patch"
+		}
+	}
+	// only separators differ
+	return "patch"
+}
+
+// components splits an upstream version at non-alphanumeric characters,
+// dropping leading zeros of numeric parts.
+func components(v string) []string {
+	parts := strings.FieldsFunc(v, func(r rune) bool { return r > 0x7f || !isAlnum(byte(r)) })
+	for i, p := range parts {
+		if isDigit(p[0]) {
+			if t := strings.TrimLeft(p, "0"); t != "" {
+				parts[i] = t
+			} else {
+				parts[i] = "0"
+			}
+		}
+	}
+	return parts
+}
@@ -0,0 +1,44 @@
+package version
+
+import "testing"
+
+func TestBump(t *testing.T) {
+	seen := map[string]bool{}
+	for _, tc := range []struct {
+		installed, candidate, want string
+	}{
+		{"", "1.0-1", ""},
+		{"1.0-1", "1.0-1", ""},
+		{"1:2.0-1", "2:1.0-1", "epoch"},
+		{"2.0-1", "1:2.0-1", "epoch"},
+		{"0:2.0-1", "2.0-2", "release"},
+		{"1.2.3-1", "2.0.0-1", "major"},
+		{"1.2.3-1", "1.3.0-1", "minor"},
+		{"1.2.3-1", "1.2.4-1", "patch"},
+		{"1.2.3-1", "1.2.3.1-1", "patch"},
+		{"1.2.3-1", "1.2.3-2", "release"},
+		{"1.2", "1.3", "minor"},
+		{"1.2", "1.2-1", "release"},
+		// leading zeros don't count as a change of the component
+		{"2024.01.5-1", "2024.1.6-1", "patch"},
+		{"1.02.3-1", "1.2.4-1", "patch"},
+		// letters stay part of their component
+		{"1.0a-1", "1.0b-1", "minor"},
+		{"1.2.3a-1", "1.2.3b-1", "patch"},
+		{"3.0.13-0ubuntu3.1", "3.0.13-0ubuntu3.2", "release"},
+		{"7.88.1-10+deb12u6", "7.88.1-10+deb12u7", "release"},
+		{"5.14.0-427.28.1.el9_4", "5.14.0-427.31.1.el9_4", "release"},
+		{"249.11-0ubuntu3.11", "252.30-1~deb12u2", "major"},
+	} {
+		got := Bump(tc.installed, tc.candidate)
+		if got != tc.want {
+			t.Errorf("Bump(%q, %q) = %q, want %q", tc.installed, tc.candidate, got, tc.want)
+		}
+		seen[got] = true
+	}
+	for _, b := range Bumps {
+		if !seen[b] {
+			t.Errorf("no test for %q", b)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+// Package version compares distribution package versions.
+package version
+
+import "strings"
+
+// CompareDeb compares two Debian package versions ([epoch:]upstream[-revision])
+// following dpkg. It returns -1, 0 or 1.
+func CompareDeb(a, b string) int {
+	ea, ua, ra := splitDeb(a)
+	eb, ub, rb := splitDeb(b)
+	if c := compareNum(ea, eb); c != 0 {
+		return c
+	}
+	if c := verrevcmp(ua, ub); c != 0 {
+		return c
+	}
+	return verrevcmp(ra, rb)
+}
+
+// splitDeb splits a Debian version into epoch, upstream version and revision.
+func splitDeb(v string) (epoch, upstream, revision string) {
+	v = strings.TrimSpace(v)
+	epoch = "0"
+	if i := strings.Index(v, ":"); i >= 0 {
+		epoch, v = v[:i], v[i+1:]
+	}
+	upstream = v
+	if i := strings.LastIndex(v, "-"); i >= 0 {
+		upstream, revision = v[:i], v[i+1:]
+	}
+	return epoch, upstream, revision
+}
+
+// compareNum compares two unsigned decimal strings without overflow.
+func compareNum(a, b string) int {
+	a = strings.TrimLeft(a, "0")
+	b = strings.TrimLeft(b, "0")
+	if len(a) != len(b) {
+		return sign(len(a) - len(b))
+	}
+	return sign(strings.Compare(a, b))
+}
+
+// debOrder is the dpkg sort weight of a non-digit character: '~' sorts before
+// everything (even the end of the string), letters before other characters.
+func debOrder(s string, i int) int {
+	if i >= len(s) {
+		return 0
+	}
+	c := s[i]
+	switch {
+	case isDigit(c):
+		return 0
+	case isAlpha(c):
+		return int(c)
+	case c == '~':
+		return -1
+	default:
+		return int(c) + 256
+	}
+}
+
+// verrevcmp is dpkg's comparison of upstream versions and revisions.
+func verrevcmp(a, b string) int {
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		firstDiff := 0
+		for (i < len(a) && !isDigit(a[i])) || (j < len(b) && !isDigit(b[j])) {
+			ac, bc := debOrder(a, i), debOrder(b, j)
+			if ac != bc {
+				return sign(ac - bc)
+			}
+			i++
+			j++
+		}
+		for i < len(a) && a[i] == '0' {
+			i++
+		}
+		for j < len(b) && b[j] == '0' {
+			j++
+		}
+		for i < len(a) && isDigit(a[i]) && j < len(b) && isDigit(b[j]) {
+			if firstDiff == 0 {
+				firstDiff = int(a[i]) - int(b[j])
+			}
+			i++
+			j++
+		}
+		if i < len(a) && isDigit(a[i]) {
+			return 1
+		}
+		if j < len(b) && isDigit(b[j]) {
+			return -1
+		}
+		if firstDiff != 0 {
+			return sign(firstDiff)
+		}
+	}
+	return 0
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+func isAlpha(c byte) bool { return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') }
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,58 @@
+package version
+
+import "testing"
+
+func TestCompareDeb(t *testing.T) {
+	// expected results as computed by dpkg --compare-versions
+	for _, tc := range []struct {
+		a, b string
+		want int
+	}{
+		{"1.0", "1.0", 0},
+		{"1.0", "1.1", -1},
+		{"1.10", "1.9", 1},
+		// tilde sorts before everything, even the end of the string
+		{"1.0~rc1", "1.0", -1},
+		{"1.0~rc1", "1.0~rc2", -1},
+		{"1.0~~", "1.0~~a", -1},
+		{"1.0~~a", "1.0~", -1},
+		{"1.0~", "1.0", -1},
+		{"2.30~git1-1", "2.30-1", -1},
+		// caret is an ordinary non-letter for dpkg
+		{"1.0^1", "1.0", 1},
+		{"1.0^1", "1.0.1", 1},
+		// epoch
+		{"1:0.1", "2.0", 1},
+		{"0:1.0", "1.0", 0},
+		{"2:1.0", "10:0.1", -1},
+		{"1:1.0-1", "1:1.0-2", -1},
+		// leading zeros
+		{"1.002", "1.2", 0},
+		{"1.0-01", "1.0-1", 0},
+		{"007:1.0", "7:1.0", 0},
+		{"1.0001", "1.0039", -1},
+		// letters sort after the end of the string, before other characters
+		{"1.0", "1.0a", -1},
+		{"1.0a", "1.0b", -1},
+		{"1.0a", "1.0+", -1},
+		{"1.0a", "1.0.1", -1},
+		{"1.0+dfsg", "1.0.1", -1},
+		{"10a", "9z", 1},
+		// revision: missing equals "0", hyphens belong to the upstream version
+		{"1.0", "1.0-0", 0},
+		{"1.0", "1.0-1", -1},
+		{"1.0-1-1", "1.0-1", 1},
+		{"1.0-1-1", "1.0-2", 1},
+		{"3.0.13-0ubuntu3.1", "3.0.13-0ubuntu3.2", -1},
+		{"252.30-1~deb12u2", "252.26-1~deb12u2", 1},
+		{"7.88.1-10+deb12u7", "7.88.1-10+deb12u6", 1},
+		{"5.15.0.118.118", "5.15.0.117.117", 1},
+	} {
+		if got := CompareDeb(tc.a, tc.b); got != tc.want {
+			t.Errorf("CompareDeb(%q, %q) = %d, want %d", tc.a, tc.b, got, tc.want)
+		}
+		if got := CompareDeb(tc.b, tc.a); got != -tc.want {
+			t.Errorf("CompareDeb(%q, %q) = %d, want %d", tc.b, tc.a, got, -tc.want)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package version
+
+import "strings"
+
+// CompareRPM compares two RPM versions ([epoch:]version[-release]) following
+// rpm's EVR comparison. The release is only compared if both have one.
+// It returns -1, 0 or 1.
+func CompareRPM(a, b string) int {
+	ea, va, ra := splitEVR(a)
+	eb, vb, rb := splitEVR(b)
+	if c := compareNum(ea, eb); c != 0 {
+		return c
+	}
+	if c := Rpmvercmp(va, vb); c != 0 {
+		return c
+	}
+	if ra == "" || rb == "" {
+		return 0
+	}
+	return Rpmvercmp(ra, rb)
+}
+
+// splitEVR splits an RPM version into epoch, version and release.
+func splitEVR(v string) (epoch, ver, release string) {
+	v = strings.TrimSpace(v)
+	epoch = "0"
+	if i := strings.Index(v, ":"); i >= 0 {
+		epoch, v = v[:i], v[i+1:]
+	}
+	ver = v
+	if i := strings.LastIndex(v, "-"); i >= 0 {
+		ver, release = v[:i], v[i+1:]
+	}
+	return epoch, ver, release
+}
+
+// Rpmvercmp is rpm's segment-wise comparison of a version or release string,
+// including the '~' (sorts before) and '^' (sorts after) separators.
+func Rpmvercmp(a, b string) int {
+	if a == b {
+		return 0
+	}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		for i < len(a) && !isAlnum(a[i]) && a[i] != '~' && a[i] != '^' {
+			i++
+		}
+		for j < len(b) && !isAlnum(b[j]) && b[j] != '~' && b[j] != '^' {
+			j++
+		}
+
+		// tilde sorts before everything, even the end of the string
+		if at(a, i) == '~' || at(b, j) == '~' {
+			if at(a, i) != '~' {
+				return 1
+			}
+			if at(b, j) != '~' {
+				return -1
+			}
+			i++
+			j++
+			continue
+		}
+
+		// caret sorts after the end of the string but before anything else
+		if at(a, i) == '^' || at(b, j) == '^' {
+			if i >= len(a) {
+				return -1
+			}
+			if j >= len(b) {
+				return 1
+			}
+			if a[i] != '^' {
+				return 1
+			}
+			if b[j] != '^' {
+				return -1
+			}
+			i++
+			j++
+			continue
+		}
+
+		if i >= len(a) || j >= len(b) {
+			break
+		}
+
+		si, sj := i, j
+		isNum := isDigit(a[i])
+		if isNum {
+			for i < len(a) && isDigit(a[i]) {
+				i++
+			}
+			for j < len(b) && isDigit(b[j]) {
+				j++
+			}
+		} else {
+			for i < len(a) && isAlpha(a[i]) {
+				i++
+			}
+			for j < len(b) && isAlpha(b[j]) {
+				j++
+			}
+		}
+		segA, segB := a[si:i], b[sj:j]
+		if segB == "" {
+			// numeric segments are newer than alpha ones
+			if isNum {
+				return 1
+			}
+			return -1
+		}
+		if isNum {
+			if c := compareNum(segA, segB); c != 0 {
+				return c
+			}
+			continue
+		}
+		if c := strings.Compare(segA, segB); c != 0 {
+			return c
+		}
+	}
+	if i >= len(a) && j >= len(b) {
+		return 0
+	}
+	if i >= len(a) {
+		return -1
+	}
+	return 1
+}
+
+func at(s string, i int) byte {
+	if i >= len(s) {
+		return 0
+	}
+	return s[i]
+}
+
+func isAlnum(c byte) bool { return isDigit(c) || isAlpha(c) }
@@ -0,0 +1,88 @@
+package version
+
+import "testing"
+
+func TestRpmvercmp(t *testing.T) {
+	// from rpm's own rpmvercmp test suite
+	for _, tc := range []struct {
+		a, b string
+		want int
+	}{
+		{"1.0", "1.0", 0},
+		{"1.0", "2.0", -1},
+		{"2.0.1", "2.0", 1},
+		{"4.999.9", "5.0", -1},
+		{"20101121", "20101122", -1},
+		// alpha vs numeric segments
+		{"2.0.1a", "2.0.1", 1},
+		{"5.5p1", "5.5p2", -1},
+		{"5.5p10", "5.5p1", 1},
+		{"10xyz", "10.1xyz", -1},
+		{"xyz10", "xyz10.1", -1},
+		{"xyz.4", "8", -1},
+		{"xyz.4", "2", -1},
+		{"5.5p2", "5.6p1", -1},
+		{"6.0.rc1", "6.0", 1},
+		{"10b2", "10a1", 1},
+		{"1.0a", "1.0aa", -1},
+		{"1.0a", "1.0.1", -1},
+		// leading zeros
+		{"10.0001", "10.1", 0},
+		{"10.0001", "10.0039", -1},
+		// separators only separate
+		{"2.0", "2_0", 0},
+		{"a+", "a_", 0},
+		{"+a", "_a", 0},
+		{"_+", "_", 0},
+		// tilde sorts before everything, even the end of the string
+		{"1.0~rc1", "1.0", -1},
+		{"1.0~rc1", "1.0~rc2", -1},
+		{"1.0~rc1~git123", "1.0~rc1", -1},
+		// caret sorts after the end of the string, before anything else
+		{"1.0^", "1.0", 1},
+		{"1.0^git1", "1.0", 1},
+		{"1.0^git1", "1.0^git2", -1},
+		{"1.0^git1", "1.01", -1},
+		{"1.0^20160101", "1.0.1", -1},
+		{"1.0^20160101^git1", "1.0^20160101", 1},
+		{"1.0~rc1^git1", "1.0~rc1", 1},
+		{"1.0^git1~pre", "1.0^git1", -1},
+	} {
+		if got := Rpmvercmp(tc.a, tc.b); got != tc.want {
+			t.Errorf("Rpmvercmp(%q, %q) = %d, want %d", tc.a, tc.b, got, tc.want)
+		}
+		if got := Rpmvercmp(tc.b, tc.a); got != -tc.want {
+			t.Errorf("Rpmvercmp(%q, %q) = %d, want %d", tc.b, tc.a, got, -tc.want)
+		}
+	}
+}
+
+func TestCompareRPM(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		want int
+	}{
+		{"3.0.7-27.el9", "3.0.7-28.el9", -1},
+		{"5.14.0-427.28.1.el9_4", "5.14.0-427.31.1.el9_4", -1},
+		{"2.34-100.el9", "2.34-100.el9_4.2", -1},
+		// epoch
+		{"1:3.0.7-27.el9", "3.0.7-28.el9", 1},
+		{"0:3.0.7-27.el9", "3.0.7-27.el9", 0},
+		{"1:1.0-1", "2:0.1-1", -1},
+		{"01:1.0-1", "1:1.0-1", 0},
+		// a missing release matches any release
+		{"3.0.7", "3.0.7-28.el9", 0},
+		{"3.0.7-28.el9", "3.0.7", 0},
+		{"3.0.6", "3.0.7-1", -1},
+		// the release is compared with rpmvercmp
+		{"1.0-1.el9", "1.0-1.el9_1", -1},
+		{"1.0-1~beta", "1.0-1", -1},
+	} {
+		if got := CompareRPM(tc.a, tc.b); got != tc.want {
+			t.Errorf("CompareRPM(%q, %q) = %d, want %d", tc.a, tc.b, got, tc.want)
+		}
+		if got := CompareRPM(tc.b, tc.a); got != -tc.want {
+			t.Errorf("CompareRPM(%q, %q) = %d, want %d", tc.b, tc.a, got, -tc.want)
+		}
+	}
+}